@@ -0,0 +1,153 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+import "github.com/gonyyi/gosl"
+
+// get.go
+// Get looks up a value by a path without unmarshalling the whole JSON.
+// A path is a dotted name with optional array indexes.
+//
+// Eg. data: {"a":{"b":[0,1,{"c":"found"}]}}
+//     Get(data, "a.b[2].c")        // => found, TypeString, true
+//     GetInt(data, "a.b[1]")       // => 1, true
+//     Get(data, "a.b")             // => [0,1,{"c":"found"}], TypeArray, true
+//     Get([]byte(`[1,[2,3]]`), "[1][0]") // => 2, TypeNumber, true
+
+// Get will find a value of the path from data. For TypeString, value will not
+// include the quotes and escapes are not resolved (see GetString). For TypeObject
+// and TypeArray, value will be the whole object or array including brackets.
+func Get(data []byte, path string) (value []byte, typ Type, ok bool) {
+	tok, ok := get(data, path)
+	return tok.Value, tok.Type, ok
+}
+
+// GetString will find a string value of the path. If the string has no escapes,
+// out points to data directly and dst is not used; otherwise the unescaped
+// string is appended to dst and returned.
+func GetString(dst gosl.Buf, data []byte, path string) (out []byte, ok bool) {
+	tok, ok := get(data, path)
+	if !ok || tok.Type != TypeString {
+		return dst, false
+	}
+	if !tok.escaped {
+		return tok.Value, true
+	}
+	return Unescape(dst, tok.Value), true
+}
+
+// GetInt will find an integer value of the path.
+func GetInt(data []byte, path string) (i int, ok bool) {
+	if tok, ok := get(data, path); ok {
+		return tok.Int()
+	}
+	return 0, false
+}
+
+// GetFloat will find a number value of the path.
+func GetFloat(data []byte, path string) (f float64, ok bool) {
+	if tok, ok := get(data, path); ok {
+		return tok.Float()
+	}
+	return 0, false
+}
+
+// GetBool will find a boolean value of the path.
+func GetBool(data []byte, path string) (value, ok bool) {
+	if tok, ok := get(data, path); ok && tok.Type == TypeBool {
+		return tok.Bool(), true
+	}
+	return false, false
+}
+
+// get walks data for the path and returns the token of the value
+func get(data []byte, path string) (tok Token, ok bool) {
+	var s Scanner
+	s.Init(data)
+	if tok, ok = s.Next(); !ok {
+		return tok, false
+	}
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+			continue
+		case '[':
+			j := i + 1
+			for j < len(path) && path[j] != ']' {
+				j++
+			}
+			idx, ok := gosl.Atoi(path[i+1 : j])
+			if j == len(path) || !ok || idx < 0 {
+				return tok, false
+			}
+			if tok, ok = getIndex(&s, tok, idx); !ok {
+				return tok, false
+			}
+			i = j + 1
+		default:
+			j := i
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			if tok, ok = getKey(&s, tok, path[i:j]); !ok {
+				return tok, false
+			}
+			i = j
+		}
+	}
+
+	if tok.Type == TypeObject || tok.Type == TypeArray {
+		if tok.Value, ok = s.Skip(tok); !ok {
+			return tok, false
+		}
+	}
+	return tok, true
+}
+
+// getKey finds a value of the key from the object. s should be right after obj.
+func getKey(s *Scanner, obj Token, key string) (tok Token, ok bool) {
+	if obj.Type != TypeObject {
+		return tok, false
+	}
+	for {
+		if tok, ok = s.Next(); !ok || tok.Type == TypeObjectEnd {
+			return tok, false
+		}
+		match := keyEqual(tok, key)
+		if tok, ok = s.Next(); !ok || match {
+			return tok, ok
+		}
+		if _, ok = s.Skip(tok); !ok {
+			return tok, false
+		}
+	}
+}
+
+// getIndex finds idx-th value of the array. s should be right after arr.
+func getIndex(s *Scanner, arr Token, idx int) (tok Token, ok bool) {
+	if arr.Type != TypeArray {
+		return tok, false
+	}
+	for i := 0; ; i++ {
+		if tok, ok = s.Next(); !ok || tok.Type == TypeArrayEnd {
+			return tok, false
+		}
+		if i == idx {
+			return tok, true
+		}
+		if _, ok = s.Skip(tok); !ok {
+			return tok, false
+		}
+	}
+}
+
+// keyEqual compares key token with a name.
+func keyEqual(tok Token, name string) bool {
+	if !tok.escaped {
+		return string(tok.Value) == name
+	}
+	var tmp [128]byte
+	return string(Unescape(tmp[:0], tok.Value)) == name
+}
@@ -0,0 +1,97 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+var testGet = []byte(`{"name":"Gon","esc":"a\tb","age":100,"score":1.5,"ok":true,` +
+	`"a":{"b":[0,1,{"c":"found"}],"x\/y":null},"arr":[[1,2],[3,4]]}`)
+
+func TestGet(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+
+	t.Run("Get", func(t *testing.T) {
+		v, typ, ok := goslj.Get(testGet, "a.b[2].c")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, typ == goslj.TypeString)
+		gosl.Test(t, "found", string(v))
+
+		v, typ, ok = goslj.Get(testGet, "a.b")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, typ == goslj.TypeArray)
+		gosl.Test(t, `[0,1,{"c":"found"}]`, string(v))
+
+		v, typ, _ = goslj.Get(testGet, "a.x/y")
+		gosl.Test(t, true, typ == goslj.TypeNull)
+		gosl.Test(t, "null", string(v))
+
+		v, _, _ = goslj.Get(testGet, "arr[1][0]")
+		gosl.Test(t, "3", string(v))
+
+		v, _, _ = goslj.Get([]byte(`[1,[2,3]]`), "[1][1]")
+		gosl.Test(t, "3", string(v))
+
+		v, typ, _ = goslj.Get(testGet, "")
+		gosl.Test(t, true, typ == goslj.TypeObject)
+		gosl.Test(t, string(testGet), string(v))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, path := range []string{"nope", "a.b[3]", "a.b[-1]", "a.b[x]", "a.b[1", "name.x", "age[0]"} {
+			_, _, ok := goslj.Get(testGet, path)
+			gosl.Test(t, false, ok)
+		}
+		_, _, ok := goslj.Get([]byte(`{"a":1,`), "b")
+		gosl.Test(t, false, ok)
+	})
+
+	t.Run("Typed", func(t *testing.T) {
+		s, ok := goslj.GetString(buf.Reset(), testGet, "name")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, "Gon", string(s))
+		gosl.Test(t, 0, buf.Len()) // not escaped, buf isn't used
+
+		s, _ = goslj.GetString(buf.Reset(), testGet, "esc")
+		gosl.Test(t, "a\tb", string(s))
+
+		_, ok = goslj.GetString(buf.Reset(), testGet, "age")
+		gosl.Test(t, false, ok)
+
+		i, ok := goslj.GetInt(testGet, "age")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, 100, i)
+
+		f, ok := goslj.GetFloat(testGet, "score")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, f == 1.5)
+
+		b, ok := goslj.GetBool(testGet, "ok")
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, b)
+
+		_, ok = goslj.GetBool(testGet, "name")
+		gosl.Test(t, false, ok)
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	buf := make(gosl.Buf, 0, 1024)
+
+	b.Run("GetString", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			goslj.GetString(buf.Reset(), testGet, "a.b[2].c")
+		}
+	})
+	b.Run("GetInt", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			goslj.GetInt(testGet, "arr[1][0]")
+		}
+	})
+}
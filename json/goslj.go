@@ -9,46 +9,121 @@ package goslj
 
 import "github.com/gonyyi/gosl"
 
-var BufferSize = 1024 // Sets JSON's buffer size when created
+var (
+	BufferSize    = 1024      // Sets JSON's buffer size when created
+	MaxBufferSize = 64 * 1024 // Sets Pool's default max buffer size, larger JSON won't be returned to the Pool
+)
 
-// NewPool will create a pool of JSON
+// MaxDepth is a maximum depth of nested objects and arrays.
+const MaxDepth = 64
+
+var (
+	ErrSyntax        = gosl.NewError("goslj: syntax error")
+	ErrUnexpectedEnd = gosl.NewError("goslj: unexpected end of JSON")
+	ErrInvalidEscape = gosl.NewError("goslj: invalid escape")
+	ErrControlChar   = gosl.NewError("goslj: control character in string")
+	ErrInvalidUTF8   = gosl.NewError("goslj: invalid UTF-8")
+	ErrTooDeep       = gosl.NewError("goslj: exceeded max depth")
+	ErrMismatch      = gosl.NewError("goslj: mismatched close")
+	ErrShortWrite    = gosl.NewError("goslj: short write")
+	ErrDuplicateKey  = gosl.NewError("goslj: duplicate key")
+)
+
+// NewPool will create a pool of JSON.
+// JSON grown larger than MaxBufferSize will not be returned to the pool.
 func NewPool(PoolSize int) *Pool {
-	p := &Pool{}
-	p.pool = p.pool.Init(PoolSize)
-	p.pool.New = func() interface{} {
-		kvj := &JSON{
-			buf: make(gosl.Buf, 0, BufferSize),
-		}
-		p.created += 1 // todo: need mutex
-		kvj.pool = p
-		return kvj
+	return &Pool{
+		pool:       make(chan *JSON, PoolSize),
+		mu:         gosl.NewMutex(),
+		maxBufSize: MaxBufferSize,
 	}
-	return p
 }
 
-// Pool is JSON pool
+// Pool is JSON pool. Pool is safe for concurrent use.
 type Pool struct {
-	pool    gosl.Pool
-	created int
-	inUse   int
+	pool       chan *JSON
+	mu         gosl.Mutex // for stats
+	stats      PoolStats
+	maxBufSize int
+}
+
+// PoolStats holds counters of the Pool.
+type PoolStats struct {
+	Created   int // how many JSON were created
+	InUse     int // how many JSON are obtained by Get() and not returned yet
+	Discarded int // how many JSON were dropped because the pool was full
+	Oversized int // how many JSON were dropped because its buffer grew larger than max buffer size
+}
+
+// SetMaxBufferSize sets max buffer size for this pool. If size is 0 or less, there won't be any limit.
+// This should be set before the pool is being used.
+func (p *Pool) SetMaxBufferSize(size int) *Pool {
+	p.maxBufSize = size
+	return p
 }
 
 // Stats will return how many objects were created and how many are in use.
 func (p *Pool) Stats() (created, inUse int) {
-	return p.created, p.inUse
+	p.mu.Lock()
+	created, inUse = p.stats.Created, p.stats.InUse
+	p.mu.Unlock()
+	return created, inUse
+}
+
+// Counters will return all counters of the pool including discarded and oversized objects.
+func (p *Pool) Counters() (stats PoolStats) {
+	p.mu.Lock()
+	stats = p.stats
+	p.mu.Unlock()
+	return stats
 }
 
 // Get will obtain *JSON from the pool
 func (p *Pool) Get() *JSON {
-	p.inUse += 1
-	return p.pool.Get().(*JSON).Reset()
+	var j *JSON
+	created := 0
+
+	select {
+	case j = <-p.pool: // Reuse
+	default:
+		j = &JSON{
+			buf:  make(gosl.Buf, 0, BufferSize),
+			pool: p,
+		}
+		created = 1
+	}
+
+	p.mu.Lock()
+	p.stats.Created += created
+	p.stats.InUse += 1
+	p.mu.Unlock()
+	return j.Reset()
 }
 
 // Put will put *JSON to the pool
 // This can be done by `*JSON.Putback()` as well
 func (p *Pool) Put(kvj *JSON) {
-	p.inUse -= 1
-	p.pool.Put(kvj)
+	kvj.flag, kvj.keyMode = 0, KeyAllow
+	kvj.out, kvj.highWater = nil, 0
+
+	oversized := p.maxBufSize > 0 && cap(kvj.buf) > p.maxBufSize
+	discarded := false
+	if !oversized {
+		select {
+		case p.pool <- kvj: // PUT BACK
+		default: // DISCARD, POOL IS FULL
+			discarded = true
+		}
+	}
+
+	p.mu.Lock()
+	p.stats.InUse -= 1
+	if oversized {
+		p.stats.Oversized += 1
+	} else if discarded {
+		p.stats.Discarded += 1
+	}
+	p.mu.Unlock()
 }
 
 // NewJSON takes a buffer size and creates a JSON
@@ -63,79 +138,367 @@ func NewJSON(bufSize int) *JSON {
 
 // JSON is a very simple writer for JSON without memory allocation
 type JSON struct {
-	pool *Pool // to able to self-return
-	buf  gosl.Buf
+	pool  *Pool // to able to self-return
+	buf   gosl.Buf
+	flag  flag
+	out   gosl.Writer         // when set by SetOutput(), buffer will be flushed to out
+	depth int                 // current depth of nested objects and arrays
+	stack [MaxDepth + 1]level // stack[0] is for the top level
+	err   error
+
+	highWater int // flush when the buffer reaches this size
+
+	keyMode KeyMode             // see CheckKeys()
+	keys    []keyEntry          // keys of open objects when CheckKeys() or SortKeys() is used
+	keyBase [MaxDepth + 1]int32 // index of keys where each level starts
+	keysBuf [16]keyEntry        // initial storage of keys to avoid allocation
+	dups    int                 // number of duplicate keys found
+}
+
+// flag holds options of JSON
+type flag uint8
+
+const (
+	flagHTMLSafe flag = 1 << iota
+	flagSortKeys
+)
+
+// level holds a kind of object/array and if a comma is needed for next value
+type level uint8
+
+const (
+	levelObject level = 1 << iota
+	levelArray
+	levelComma // a value has been written, next one needs a comma
+)
+
+// HTMLSafe will set HTML-safe mode. When on, `<`, `>`, `&`, U+2028 and U+2029
+// in strings will be escaped as \u003c, \u003e, \u0026, \u2028, \u2029 so
+// the output can be embedded in HTML <script> tags.
+// Options are kept after Reset(), but cleared when returned to a Pool.
+func (j *JSON) HTMLSafe(on bool) *JSON {
+	if on {
+		j.flag |= flagHTMLSafe
+	} else {
+		j.flag &^= flagHTMLSafe
+	}
+	return j
+}
+
+// SetOutput will bind the JSON to Writer w for streaming. Whenever the buffer
+// reaches highWater bytes, completed values will be flushed to w, so a very large
+// JSON can be written with a bounded buffer. End() and Flush() will write the rest.
+// If w is nil, streaming will be disabled. A write error can be found by Err().
+// Like HTMLSafe(), output is kept after Reset(), but cleared when returned to a Pool.
+func (j *JSON) SetOutput(w gosl.Writer, highWater int) *JSON {
+	if highWater < 1 {
+		highWater = BufferSize
+	}
+	j.out, j.highWater = w, highWater
+	return j
+}
+
+// Flush will write the buffer to the output set by SetOutput(), and clear the buffer.
+// When there's no output or an error has occurred earlier, nothing will be written.
+func (j *JSON) Flush() *JSON {
+	if j.out == nil || len(j.buf) == 0 {
+		return j
+	}
+	if j.err == nil {
+		n, err := j.out.Write(j.buf)
+		if err == nil && n != len(j.buf) {
+			err = ErrShortWrite
+		}
+		j.fail(err)
+	}
+	j.buf = j.buf.Reset() // even with an error, don't let the buffer grow
+	return j
 }
 
 // Reset will clear current JSON
 func (j *JSON) Reset() *JSON {
 	j.buf = j.buf.Reset()
+	j.depth = 0
+	j.stack[0] = 0
+	j.err = nil
+	j.keys = j.keys[:0]
+	j.dups = 0
 	return j
 }
 
+// Err returns the first error found while building the JSON such as
+// ErrMismatch (closing what isn't opened) or ErrTooDeep.
+func (j *JSON) Err() error {
+	return j.err
+}
+
+// Bytes returns current buffer of the JSON. This is valid until the JSON is modified.
+func (j *JSON) Bytes() []byte {
+	return j.buf
+}
+
+// Len returns current size of the buffer
+func (j *JSON) Len() int {
+	return len(j.buf)
+}
+
+// Depth returns how many objects and arrays are currently open
+func (j *JSON) Depth() int {
+	return j.depth
+}
+
 // Start will begin JSON
 func (j *JSON) Start() *JSON {
-	return j.b('{')
+	return j.elem().open('{', levelObject)
 }
 
-// End will remove extra comma if exists, and also add '}'
+// StartArray will begin JSON with an array instead of an object. eg. `[1,2,3]`
+func (j *JSON) StartArray() *JSON {
+	return j.elem().open('[', levelArray)
+}
+
+// End will close all objects and arrays still open, including one from Start().
+// If output is set by SetOutput(), it will also Flush().
 func (j *JSON) End() *JSON {
-	return j.rmLast(',').b('}')
+	for j.depth > 0 {
+		if j.stack[j.depth]&levelArray != 0 {
+			j.close(']', levelArray)
+		} else {
+			j.close('}', levelObject)
+		}
+	}
+	return j.Flush()
+}
+
+// Object will begin a nested object. Within an array, name will be ignored.
+// This must be closed with EndObject() or End().
+func (j *JSON) Object(name string) *JSON {
+	return j.key(name).open('{', levelObject)
+}
+
+// EndObject will close the object opened by Object() or Start()
+func (j *JSON) EndObject() *JSON {
+	return j.close('}', levelObject)
+}
+
+// Array will begin a nested array. Within an array, name will be ignored.
+// Values can be added with Add methods such as AddString(), AddInt().
+// This must be closed with EndArray() or End().
+func (j *JSON) Array(name string) *JSON {
+	return j.key(name).open('[', levelArray)
+}
+
+// EndArray will close the array opened by Array()
+func (j *JSON) EndArray() *JSON {
+	return j.close(']', levelArray)
 }
 
 // String will add key-value pair of string
 func (j *JSON) String(name, s string) *JSON {
-	return j.string(name).b(':').string(s).b(',')
+	return j.key(name).string(s)
 }
 
 // Int will add key-value pair of integer
 func (j *JSON) Int(name string, i int) *JSON {
-	return j.string(name).b(':').int(i).b(',')
+	return j.key(name).int(i)
 }
 
 // IntArray will add integers
 func (j *JSON) IntArray(name string, nums ...int) *JSON {
-	j.string(name).b(':')
-	j.b('[')
+	j.Array(name)
 	for _, num := range nums {
-		j.int(num)
-		j.b(',')
+		j.elem().int(num)
 	}
-	j.rmLast(',')
-	j.b(']')
-	j.b(',')
-	return j
+	return j.EndArray()
 }
 
 // StringArray will add strings
 func (j *JSON) StringArray(name string, s ...string) *JSON {
-	j.string(name).b(':')
-	j.b('[')
+	j.Array(name)
 	for _, v := range s {
-		j.string(v)
-		j.b(',')
+		j.elem().string(v)
 	}
-	j.rmLast(',')
-	j.b(']')
-	j.b(',')
-	return j
+	return j.EndArray()
+}
+
+// Float will add key-value pair of float with decimal points dec (up to 18).
+// As JSON does not support NaN and Inf, they will be written as null.
+// Values outside int64 range (|f| >= 2^63) are also written as null.
+func (j *JSON) Float(name string, f float64, dec uint8) *JSON {
+	return j.key(name).float(f, dec)
+}
+
+// Bool will add key-value pair of boolean
+func (j *JSON) Bool(name string, t bool) *JSON {
+	return j.key(name).bool(t)
+}
+
+// Null will add a key with null value
+func (j *JSON) Null(name string) *JSON {
+	return j.key(name).null()
+}
+
+// Raw will add a key with pre-encoded JSON fragment p as-is.
+// If p is empty, null will be used.
+func (j *JSON) Raw(name string, p []byte) *JSON {
+	return j.key(name).raw(p)
+}
+
+// Base64 will add binary p as a base64 (standard encoding with padding) string
+func (j *JSON) Base64(name string, p []byte) *JSON {
+	return j.key(name).base64(p)
+}
+
+// Hex will add binary p as a hex string
+func (j *JSON) Hex(name string, p []byte) *JSON {
+	return j.key(name).hex(p)
+}
+
+// Timestamp will add gosl.Timestamp. If formatted is false, it will be a number (eg. 20220321130521630),
+// otherwise it will be a string of gosl.TDefault format (eg. "2022/03/21 13:05:21.630").
+func (j *JSON) Timestamp(name string, t gosl.Timestamp, formatted bool) *JSON {
+	return j.key(name).timestamp(t, formatted)
+}
+
+// FloatArray will add floats with decimal points dec
+func (j *JSON) FloatArray(name string, dec uint8, f ...float64) *JSON {
+	j.Array(name)
+	for _, v := range f {
+		j.elem().float(v, dec)
+	}
+	return j.EndArray()
+}
+
+// BoolArray will add booleans
+func (j *JSON) BoolArray(name string, t ...bool) *JSON {
+	j.Array(name)
+	for _, v := range t {
+		j.elem().bool(v)
+	}
+	return j.EndArray()
+}
+
+// RawArray will add pre-encoded JSON fragments
+func (j *JSON) RawArray(name string, p ...[]byte) *JSON {
+	j.Array(name)
+	for _, v := range p {
+		j.elem().raw(v)
+	}
+	return j.EndArray()
+}
+
+// Base64Array will add binaries as base64 strings
+func (j *JSON) Base64Array(name string, p ...[]byte) *JSON {
+	j.Array(name)
+	for _, v := range p {
+		j.elem().base64(v)
+	}
+	return j.EndArray()
+}
+
+// HexArray will add binaries as hex strings
+func (j *JSON) HexArray(name string, p ...[]byte) *JSON {
+	j.Array(name)
+	for _, v := range p {
+		j.elem().hex(v)
+	}
+	return j.EndArray()
+}
+
+// TimestampArray will add gosl.Timestamp values. See Timestamp() for formatted.
+func (j *JSON) TimestampArray(name string, formatted bool, t ...gosl.Timestamp) *JSON {
+	j.Array(name)
+	for _, v := range t {
+		j.elem().timestamp(v, formatted)
+	}
+	return j.EndArray()
+}
+
+// AddString will add a string to current array
+func (j *JSON) AddString(s string) *JSON {
+	return j.elem().string(s)
+}
+
+// AddInt will add an integer to current array
+func (j *JSON) AddInt(i int) *JSON {
+	return j.elem().int(i)
+}
+
+// AddFloat will add a float to current array
+func (j *JSON) AddFloat(f float64, dec uint8) *JSON {
+	return j.elem().float(f, dec)
+}
+
+// AddBool will add a boolean to current array
+func (j *JSON) AddBool(t bool) *JSON {
+	return j.elem().bool(t)
+}
+
+// AddNull will add null to current array
+func (j *JSON) AddNull() *JSON {
+	return j.elem().null()
+}
+
+// AddRaw will add pre-encoded JSON fragment to current array
+func (j *JSON) AddRaw(p []byte) *JSON {
+	return j.elem().raw(p)
 }
 
 // Write writes JSON to the Writer
 func (j *JSON) Write(w gosl.Writer) *JSON {
-	j.rmLast(',')
 	j.buf.WriteTo(w)
 	return j
 }
 
-// Sub will take other JSON and add
+// Sub will take other JSON and add.
+// Object() and Array() can build nested JSON without a separate JSON.
 func (j *JSON) Sub(name string, src *JSON) *JSON {
 	if j == src { // do not allow self being included
 		return j
 	}
-	j.string(name).b(':')
-	j.buf = j.buf.WriteBytes(src.buf...)
-	return j.b(',')
+	return j.key(name).raw(src.buf)
+}
+
+// Marshaler is an interface for types that can append themselves to JSON without reflection.
+// AppendJSON should add members (eg. `j.String("name", u.Name)`) to the object opened by ObjectOf().
+type Marshaler interface {
+	AppendJSON(j *JSON) *JSON
+}
+
+// ObjectOf will add v as a nested object. Within an array, name will be ignored.
+// If v is nil, null will be added. Objects and arrays that v left open will be closed,
+// and Err() will return ErrMismatch.
+func (j *JSON) ObjectOf(name string, v Marshaler) *JSON {
+	if v == nil {
+		return j.key(name).null()
+	}
+	j.Object(name)
+	depth := j.depth
+	v.AppendJSON(j)
+	if j.depth != depth {
+		j.fail(ErrMismatch)
+		if j.depth < depth { // closed too much
+			return j
+		}
+		for j.depth > depth {
+			if j.stack[j.depth]&levelArray != 0 {
+				j.close(']', levelArray)
+			} else {
+				j.close('}', levelObject)
+			}
+		}
+	}
+	return j.EndObject()
+}
+
+// ArrayOf will add an array with n values where each value is added by f.
+// f should add a single value with methods such as AddString(), AddInt(), ObjectOf("", v).
+func (j *JSON) ArrayOf(name string, n int, f func(i int, j *JSON)) *JSON {
+	j.Array(name)
+	for i := 0; i < n && f != nil; i++ {
+		f(i, j)
+	}
+	return j.EndArray()
 }
 
 // Putback will return JSON to the pool if it was from the pool
@@ -153,9 +516,68 @@ func (j *JSON) b(b byte) *JSON {
 	return j
 }
 
-// rmLast will remove byte b suffix if exists
-func (j *JSON) rmLast(b byte) *JSON {
-	j.buf = j.buf.TrimSuffix(b)
+// key will add a comma if needed, and a name unless current level is an array
+func (j *JSON) key(name string) *JSON {
+	tracked := j.stack[j.depth]&levelObject != 0 && j.tracking()
+	if tracked {
+		j.keyDone()
+	}
+	j.elem()
+	if j.stack[j.depth]&levelArray == 0 {
+		start := len(j.buf)
+		j.string(name).b(':')
+		if tracked {
+			j.keyAdd(name, start)
+		}
+	}
+	return j
+}
+
+// elem will add a comma if current level already has a value
+// When streaming, this is where the buffer only has completed values, so it will flush if needed.
+func (j *JSON) elem() *JSON {
+	if j.out != nil && len(j.buf) >= j.highWater && len(j.keys) == 0 { // don't move tracked keys
+		j.Flush()
+	}
+	if j.stack[j.depth]&levelComma != 0 {
+		return j.b(',')
+	}
+	j.stack[j.depth] |= levelComma
+	return j
+}
+
+// open will add an opening bracket c and push a new level
+func (j *JSON) open(c byte, lv level) *JSON {
+	if j.depth == MaxDepth {
+		return j.fail(ErrTooDeep)
+	}
+	j.depth++
+	j.stack[j.depth] = lv
+	j.keyBase[j.depth] = int32(len(j.keys))
+	return j.b(c)
+}
+
+// close will add a closing bracket c if the current level matches lv
+func (j *JSON) close(c byte, lv level) *JSON {
+	if j.depth == 0 || j.stack[j.depth]&lv == 0 {
+		return j.fail(ErrMismatch)
+	}
+	if lv == levelObject && j.tracking() {
+		j.keyDone()
+		if j.flag&flagSortKeys != 0 {
+			j.keySort()
+		}
+		j.keys = j.keys[:j.keyBase[j.depth]]
+	}
+	j.depth--
+	return j.b(c)
+}
+
+// fail will keep the first error
+func (j *JSON) fail(err error) *JSON {
+	if j.err == nil {
+		j.err = err
+	}
 	return j
 }
 
@@ -165,22 +587,146 @@ func (j *JSON) int(i int) *JSON {
 	return j
 }
 
-// string will add string s
+// float will convert a float and add. NaN, Inf, and values
+// BytesAppendFloat can't format (outside int64) will be null.
+func (j *JSON) float(f float64, dec uint8) *JSON {
+	if f != f || f >= maxFloatInt || f <= -maxFloatInt {
+		return j.null()
+	}
+	if dec > maxFloatDec {
+		dec = maxFloatDec
+	}
+	j.buf = gosl.BytesAppendFloat(j.buf, f, dec)
+	return j
+}
+
+// bool will add true or false
+func (j *JSON) bool(t bool) *JSON {
+	j.buf = gosl.BytesAppendBool(j.buf, t)
+	return j
+}
+
+// null will add null
+func (j *JSON) null() *JSON {
+	j.buf = append(j.buf, "null"...)
+	return j
+}
+
+// raw will add pre-encoded p. If p is empty, null will be added instead.
+func (j *JSON) raw(p []byte) *JSON {
+	if len(p) == 0 {
+		return j.null()
+	}
+	j.buf = append(j.buf, p...)
+	return j
+}
+
+// base64 will add p as a base64 encoded string
+func (j *JSON) base64(p []byte) *JSON {
+	j.buf = append(j.buf, '"')
+	for i := 0; i < len(p); i += 3 {
+		var v uint32
+		n := len(p) - i
+		switch {
+		case n >= 3:
+			n = 3
+			v = uint32(p[i])<<16 | uint32(p[i+1])<<8 | uint32(p[i+2])
+		case n == 2:
+			v = uint32(p[i])<<16 | uint32(p[i+1])<<8
+		default:
+			v = uint32(p[i]) << 16
+		}
+		j.buf = append(j.buf, base64Chars[v>>18&0x3F], base64Chars[v>>12&0x3F], '=', '=')
+		if n > 1 {
+			j.buf[len(j.buf)-2] = base64Chars[v>>6&0x3F]
+		}
+		if n > 2 {
+			j.buf[len(j.buf)-1] = base64Chars[v&0x3F]
+		}
+	}
+	j.buf = append(j.buf, '"')
+	return j
+}
+
+// hex will add p as a hex string
+func (j *JSON) hex(p []byte) *JSON {
+	j.buf = append(j.buf, '"')
+	j.buf = gosl.BytesToHex(j.buf, p)
+	j.buf = append(j.buf, '"')
+	return j
+}
+
+// timestamp will add gosl.Timestamp either as a number or a formatted string
+func (j *JSON) timestamp(t gosl.Timestamp, formatted bool) *JSON {
+	if !formatted { // not using t.Append() as it pads zeros and zero-padded number is invalid in JSON
+		j.buf = gosl.BytesAppendInt(j.buf, int(t))
+		return j
+	}
+	j.buf = append(j.buf, '"')
+	j.buf = t.Format(j.buf, gosl.TDefault)
+	j.buf = append(j.buf, '"')
+	return j
+}
+
+// string will add string s. Per RFC 8259, control characters without a short escape
+// will be \u00XX, and invalid UTF-8 will be replaced with U+FFFD.
 func (j *JSON) string(s string) *JSON {
 	j.buf = j.buf.WriteBytes('"')
 
-	for i := 0; i < len(s); i++ {
-		if app := stringEscapes[s[i]]; app != 0 {
-			j.buf = append(j.buf, '\\', app)
-		} else {
-			j.buf = append(j.buf, s[i])
+	html := j.flag&flagHTMLSafe != 0
+	start := 0 // bytes from start to i can be copied as-is
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < 0x80 {
+			if c >= 0x20 && c != '"' && c != '\\' && !(html && (c == '<' || c == '>' || c == '&')) {
+				i++
+				continue
+			}
+			j.buf = append(j.buf, s[start:i]...)
+			if app := stringEscapes[c]; app != 0 {
+				j.buf = append(j.buf, '\\', app)
+			} else {
+				j.buf = append(j.buf, '\\', 'u', '0', '0', hexChars[c>>4], hexChars[c&0xF])
+			}
+			i++
+			start = i
+			continue
 		}
+
+		r, size := decodeRune(s, i)
+		switch {
+		case r == runeError && size == 1: // invalid UTF-8
+			j.buf = append(j.buf, s[start:i]...)
+			j.buf = appendRune(j.buf, runeError)
+		case html && (r == 0x2028 || r == 0x2029): // line and paragraph separators
+			j.buf = append(j.buf, s[start:i]...)
+			j.buf = append(j.buf, '\\', 'u', '2', '0', '2', hexChars[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
 	}
 
+	j.buf = append(j.buf, s[start:]...)
 	j.buf = j.buf.WriteBytes('"')
 	return j
 }
 
-// stringEscapes will hold what strings need to be escaped
-// At this point, &, <, > will not be converted to \u0026, \u003c, \u003e. Not sure if I need to...
+// stringEscapes will hold what strings need to be escaped with a short form.
+// Other control characters will be \u00XX, and in HTMLSafe mode, &, <, > will be \u0026, \u003c, \u003e.
 var stringEscapes = [256]byte{'"': '"', '\\': '\\', '\r': 'r', '\n': 'n', '\b': 'b', '\f': 'f', '\t': 't'}
+
+// hexChars is used for \u00XX escapes
+const hexChars = "0123456789abcdef"
+
+// base64Chars is the standard base64 alphabet (RFC 4648)
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// maxFloatInt is 2^63. BytesAppendFloat converts the integer part to int,
+// so anything at or beyond this (including Inf) can't be written.
+const maxFloatInt = 9223372036854775808.0
+
+// maxFloatDec is the most decimal places that fit in an int64 (10^18).
+const maxFloatDec = 18
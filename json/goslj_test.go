@@ -3,10 +3,14 @@
 package goslj_test
 
 import (
-	"github.com/gonyyi/gosl"
-	goslj "github.com/gonyyi/gosl/json"
+	"encoding/base64"
+	"io"
+	"math"
 	"os"
 	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
 )
 
 var testIn = `gon is	happy here	한글이름: 이건용` +
@@ -14,7 +18,7 @@ var testIn = `gon is	happy here	한글이름: 이건용` +
 	`so no need to 		worry {} () .: \ !@#$%^&*()_+{}[];':",<.>/?"'`
 var testOut = `{"name":"gon is\thappy here\t한글이름: 이건용\nso no need to \t\tworry {} () .: \\ !@#$%^&*()_+{}[];':\",<.>/?\"'"}`
 
-func ExampleJSON_Main() {
+func ExampleJSON_main() {
 	jp := goslj.NewPool(20) // create a JSON pool with 20 objects
 
 	j1 := jp.Get() // get JSON from the pool
@@ -61,11 +65,328 @@ func TestJSON(t *testing.T) {
 	gosl.Test(t, testOut, buf.String())
 }
 
+func TestJSON_Types(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(1024)
+	ts := gosl.Timestamp(0).Parse("2022/03/21 13:05:21.630", 0)
+
+	t.Run("Single", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().
+			Float("f", 1.2345, 2).
+			Float("nan", math.NaN(), 2).
+			Float("inf", math.Inf(-1), 2).
+			Bool("ok", true).
+			Null("nil").
+			Raw("raw", []byte(`{"a":1}`)).
+			Raw("empty", nil).
+			Hex("hex", []byte("Gon")).
+			Base64("b64", []byte("Gon")).
+			Timestamp("ts", ts, false).
+			Timestamp("tsf", ts, true).
+			End().Write(&buf)
+		gosl.Test(t, `{"f":1.23,"nan":null,"inf":null,"ok":true,"nil":null,"raw":{"a":1},"empty":null,`+
+			`"hex":"476f6e","b64":"R29u","ts":20220321130521630,"tsf":"2022/03/21 13:05:21.630"}`, buf.String())
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().
+			FloatArray("f", 1, 1.5, -2.25).
+			BoolArray("b", true, false).
+			RawArray("raw", []byte(`1`), []byte(`"a"`), nil).
+			HexArray("hex", []byte("G"), []byte("on")).
+			Base64Array("b64", []byte("G"), []byte("on")).
+			TimestampArray("ts", false, ts).
+			TimestampArray("empty", true).
+			End().Write(&buf)
+		gosl.Test(t, `{"f":[1.5,-2.2],"b":[true,false],"raw":[1,"a",null],"hex":["47","6f6e"],`+
+			`"b64":["Rw==","b24="],"ts":[20220321130521630],"empty":[]}`, buf.String())
+	})
+
+	t.Run("Float", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().
+			Float("a", 1e19, 0).
+			Float("b", 1e19, 2).
+			Float("c", -1e300, 3).
+			Float("d", 1.5, 20).
+			Float("e", -9e18, 1).
+			FloatArray("fa", 2, 1e19, -0.5).
+			Array("fb").AddFloat(-1e19, 0).AddFloat(0.25, 255).EndArray().
+			End().Write(&buf)
+		gosl.Test(t, `{"a":null,"b":null,"c":null,"d":1.500000000000000000,"e":-9000000000000000000.0,`+
+			`"fa":[null,-0.50],"fb":[null,0.250000000000000000]}`, buf.String())
+	})
+
+	t.Run("Base64", func(t *testing.T) {
+		in := []byte("any carnal pleasure.\x00\xff\xfe")
+		for i := 0; i <= len(in); i++ {
+			buf = buf.Reset()
+			j.Reset().Start().Base64("v", in[:i]).End().Write(&buf)
+			gosl.Test(t, `{"v":"`+base64.StdEncoding.EncodeToString(in[:i])+`"}`, buf.String())
+		}
+	})
+}
+
+func TestJSON_Nested(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(1024)
+
+	t.Run("Basic", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().
+			String("name", "Gon").
+			Object("address").
+			String("city", "conway").
+			Array("zip").AddInt(72034).AddInt(72035).EndArray().
+			EndObject().
+			Array("tags").
+			AddString("a").
+			Object("ignored").Int("n", 1).EndObject().
+			Array("").AddBool(true).AddNull().EndArray().
+			EndArray().
+			IntArray("empty").
+			End().Write(&buf)
+		gosl.Test(t, nil, j.Err())
+		gosl.Test(t, 0, j.Depth())
+		gosl.Test(t, `{"name":"Gon","address":{"city":"conway","zip":[72034,72035]},`+
+			`"tags":["a",{"n":1},[true,null]],"empty":[]}`, buf.String())
+	})
+
+	t.Run("End", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().Object("a").Array("b").AddInt(1).End().Write(&buf)
+		gosl.Test(t, nil, j.Err())
+		gosl.Test(t, `{"a":{"b":[1]}}`, buf.String())
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		j.Reset().Start().Array("a").EndObject()
+		gosl.Test(t, goslj.ErrMismatch, j.Err())
+		j.Reset().Start().EndObject().EndObject()
+		gosl.Test(t, goslj.ErrMismatch, j.Err())
+		j.Reset()
+		gosl.Test(t, nil, j.Err())
+	})
+
+	t.Run("TooDeep", func(t *testing.T) {
+		j.Reset()
+		for i := 0; i <= goslj.MaxDepth; i++ {
+			j.Array("")
+		}
+		gosl.Test(t, goslj.ErrTooDeep, j.Err())
+		gosl.Test(t, goslj.MaxDepth, j.Depth())
+	})
+}
+
+func TestJSON_Escape(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(1024)
+
+	tests := []struct {
+		in, out, html string
+	}{
+		{"a\x00b\x1fc", `"a\u0000b\u001fc"`, ""},
+		{"\b\f\n\r\t\"\\/", `"\b\f\n\r\t\"\\/"`, ""},
+		{"<a href='x'>&amp;</a>", `"<a href='x'>&amp;</a>"`, `"\u003ca href='x'\u003e\u0026amp;\u003c/a\u003e"`},
+		{"line\u2028para\u2029", "\"line\u2028para\u2029\"", `"line\u2028para\u2029"`},
+		{"한글 😀 \uFFFD", "\"한글 😀 \uFFFD\"", ""},
+		{"bad\xffutf8\xc3", "\"bad\uFFFDutf8\uFFFD\"", ""},
+		{"\xed\xa0\x80 \xc0\xaf", "\"\uFFFD\uFFFD\uFFFD \uFFFD\uFFFD\"", ""}, // surrogate, overlong
+	}
+
+	for _, v := range tests {
+		buf = buf.Reset()
+		j.Reset().HTMLSafe(false).AddString(v.in).Write(&buf)
+		gosl.Test(t, v.out, buf.String())
+
+		if v.html == "" {
+			v.html = v.out
+		}
+		buf = buf.Reset()
+		j.Reset().HTMLSafe(true).AddString(v.in).Write(&buf)
+		gosl.Test(t, v.html, buf.String())
+	}
+}
+
+// chunkWriter records the largest write, and fails after failAfter writes if set.
+type chunkWriter struct {
+	buf       gosl.Buf
+	writes    int
+	maxChunk  int
+	failAfter int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.failAfter > 0 && w.writes == w.failAfter {
+		return 0, io.ErrClosedPipe
+	}
+	w.writes++
+	if len(p) > w.maxChunk {
+		w.maxChunk = len(p)
+	}
+	return w.buf.Write(p)
+}
+
+func TestJSON_SetOutput(t *testing.T) {
+	expected := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(256)
+
+	j.Start().Array("nums")
+	for i := 0; i < 10000; i++ {
+		j.AddInt(i)
+	}
+	j.End().Write(&expected)
+
+	t.Run("Stream", func(t *testing.T) {
+		w := &chunkWriter{}
+		j.Reset().SetOutput(w, 256).Start().Array("nums")
+		for i := 0; i < 10000; i++ {
+			j.AddInt(i)
+		}
+		j.End()
+		gosl.Test(t, nil, j.Err())
+		gosl.Test(t, expected.String(), w.buf.String())
+		gosl.Test(t, true, w.maxChunk < 256+10) // high water + one value
+		gosl.Test(t, true, w.writes > 100)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		w := &chunkWriter{failAfter: 3}
+		j.Reset().SetOutput(w, 256).Start().Array("nums")
+		for i := 0; i < 10000; i++ {
+			j.AddInt(i)
+		}
+		j.End()
+		gosl.Test(t, io.ErrClosedPipe, j.Err())
+		gosl.Test(t, 3, w.writes)
+		gosl.Test(t, true, j.Len() < 256+10)
+	})
+
+	j.SetOutput(nil, 0) // disable
+}
+
+func TestPool(t *testing.T) {
+	t.Run("Stats", func(t *testing.T) {
+		jp := goslj.NewPool(2).SetMaxBufferSize(2048)
+		j1, j2, j3 := jp.Get(), jp.Get(), jp.Get()
+		created, inUse := jp.Stats()
+		gosl.Test(t, 3, created)
+		gosl.Test(t, 3, inUse)
+
+		j3.Raw("big", make([]byte, 4096)) // grows larger than max buffer size
+		j1.Putback()
+		j2.Putback()
+		j3.Putback()
+		gosl.Test(t, 0, jp.Counters().InUse)
+		gosl.Test(t, 1, jp.Counters().Oversized)
+		gosl.Test(t, 0, jp.Counters().Discarded)
+
+		j1, j2, j3 = jp.Get(), jp.Get(), jp.Get() // 2 from the pool, 1 new
+		gosl.Test(t, 4, jp.Counters().Created)
+		j1.Putback()
+		j2.Putback()
+		j3.Putback() // pool is full
+		gosl.Test(t, 1, jp.Counters().Discarded)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		jp := goslj.NewPool(4)
+		done := make(chan struct{})
+		for i := 0; i < 8; i++ {
+			go func(i int) {
+				for k := 0; k < 100; k++ {
+					jp.Get().Start().Int("id", i).End().Write(gosl.Discard).Putback()
+				}
+				done <- struct{}{}
+			}(i)
+		}
+		for i := 0; i < 8; i++ {
+			<-done
+		}
+		stats := jp.Counters()
+		gosl.Test(t, 0, stats.InUse)
+		gosl.Test(t, true, stats.Created-stats.Discarded <= 4) // rest are in the pool
+	})
+}
+
+type testAddress struct {
+	City  string
+	State string
+	Zip   int
+}
+
+func (a *testAddress) AppendJSON(j *goslj.JSON) *goslj.JSON {
+	return j.String("city", a.City).String("state", a.State).Int("zip", a.Zip)
+}
+
+type testPerson struct {
+	Name    string
+	Age     int
+	Address *testAddress
+	Friends []testPerson
+}
+
+func (p *testPerson) AppendJSON(j *goslj.JSON) *goslj.JSON {
+	j.String("name", p.Name).Int("age", p.Age)
+	if p.Address != nil {
+		j.ObjectOf("address", p.Address)
+	}
+	if len(p.Friends) > 0 {
+		j.ArrayOf("friends", len(p.Friends), func(i int, j *goslj.JSON) {
+			j.ObjectOf("", &p.Friends[i])
+		})
+	}
+	return j
+}
+
+type testBroken struct{}
+
+func (testBroken) AppendJSON(j *goslj.JSON) *goslj.JSON {
+	return j.Array("a").Object("")
+}
+
+func TestJSON_Marshaler(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(1024)
+	p := testPerson{
+		Name:    "Gon Yi",
+		Age:     100,
+		Address: &testAddress{City: "conway", State: "arkansas", Zip: 72034},
+		Friends: []testPerson{{Name: "A", Age: 1}, {Name: "B", Age: 2}},
+	}
+
+	t.Run("ObjectOf", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().ObjectOf("person", &p).ObjectOf("nil", nil).End().Write(&buf)
+		gosl.Test(t, nil, j.Err())
+		gosl.Test(t, `{"person":{"name":"Gon Yi","age":100,"address":{"city":"conway","state":"arkansas","zip":72034},`+
+			`"friends":[{"name":"A","age":1},{"name":"B","age":2}]},"nil":null}`, buf.String())
+	})
+
+	t.Run("ArrayOf", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().
+			ArrayOf("squares", 4, func(i int, j *goslj.JSON) { j.AddInt(i * i) }).
+			ArrayOf("empty", 0, nil).
+			End().Write(&buf)
+		gosl.Test(t, `{"squares":[0,1,4,9],"empty":[]}`, buf.String())
+	})
+
+	t.Run("Broken", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().Start().ObjectOf("b", testBroken{}).Int("next", 1).End().Write(&buf)
+		gosl.Test(t, goslj.ErrMismatch, j.Err())
+		gosl.Test(t, `{"b":{"a":[{}]},"next":1}`, buf.String())
+	})
+}
+
 func BenchmarkJSON(b *testing.B) {
 	buf := make(gosl.Buf, 0, 1024)
 	j := goslj.NewJSON(1024)
 	jp := goslj.NewPool(20)
-	discard := &gosl.Discard{}
+	discard := gosl.Discard
 	_, _ = buf, discard
 
 	b.Run("simple", func(b *testing.B) {
@@ -119,6 +440,39 @@ func BenchmarkJSON(b *testing.B) {
 		// }
 	})
 
+	b.Run("nested", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j.Reset().Start().
+				String("name", "Gon Yi").
+				Int("age", 100).
+				Object("address").
+				String("city", "conway").
+				String("state", "arkansas").
+				Int("zip", 72034).
+				EndObject().
+				Object("employer").
+				String("name", "gonn corp").
+				Int("tin", 123456789).
+				Int("income", 123456).
+				EndObject().
+				End().Write(discard)
+		}
+	})
+
+	b.Run("marshaler", func(b *testing.B) {
+		p := testPerson{
+			Name:    "Gon Yi",
+			Age:     100,
+			Address: &testAddress{City: "conway", State: "arkansas", Zip: 72034},
+			Friends: []testPerson{{Name: "A", Age: 1}, {Name: "B", Age: 2}},
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			j.Reset().Start().ObjectOf("person", &p).End().Write(discard)
+		}
+	})
+
 	b.Run("pool+simple", func(b *testing.B) {
 		// BenchmarkJSON/simple+pool-12         	11616590	        93.72 ns/op	       0 B/op	       0 allocs/op
 		b.ReportAllocs()
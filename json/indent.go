@@ -0,0 +1,92 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+// indent.go
+// Indent and Compact work on any JSON bytes, not only the ones built by goslj.
+// Like gosl.Bytes* functions, they append to dst. Strings and numbers are kept
+// as they are (no re-escaping), only whitespaces between tokens are changed.
+//
+// Eg. Debug output without encoding/json:
+//     buf, _ = goslj.Indent(buf[:0], data, "", "  ")
+//     w.Debug().Write(buf)
+
+// Compact will append src to dst without insignificant whitespaces.
+// When src is not a valid JSON, dst will be returned as-is with an error.
+func Compact(dst []byte, src []byte) (out []byte, err error) {
+	return reformat(dst, src, "", "", false)
+}
+
+// Indent will append src to dst with each element on a new line starting with prefix
+// followed by one or more copies of indent according to the nesting.
+// Like encoding/json's Indent, the first line won't have the prefix.
+// When src is not a valid JSON, dst will be returned as-is with an error.
+func Indent(dst []byte, src []byte, prefix, indent string) (out []byte, err error) {
+	return reformat(dst, src, prefix, indent, true)
+}
+
+// reformat rewrites src token by token
+func reformat(dst []byte, src []byte, prefix, indent string, pretty bool) ([]byte, error) {
+	var s Scanner
+	s.Init(src)
+	out := dst
+	prev := TypeNone
+
+	for tok, ok := s.Next(); ok; tok, ok = s.Next() {
+		isOpen := tok.Type == TypeObject || tok.Type == TypeArray
+		isEnd := tok.Type == TypeObjectEnd || tok.Type == TypeArrayEnd
+
+		// separator between prev and current token
+		switch prev {
+		case TypeNone:
+		case TypeKey:
+			out = append(out, ':')
+			if pretty {
+				out = append(out, ' ')
+			}
+		case TypeObject, TypeArray:
+			if pretty && !isEnd { // empty object and array will stay as {} and []
+				out = newline(out, prefix, indent, s.Depth()-btoi(isOpen))
+			}
+		default:
+			if !isEnd {
+				out = append(out, ',')
+			}
+			if pretty {
+				out = newline(out, prefix, indent, s.Depth()-btoi(isOpen))
+			}
+		}
+
+		if tok.Type == TypeKey || tok.Type == TypeString {
+			out = append(out, '"')
+			out = append(out, tok.Value...)
+			out = append(out, '"')
+		} else {
+			out = append(out, tok.Value...)
+		}
+		prev = tok.Type
+	}
+
+	if err := s.Err(); err != nil {
+		return dst, err
+	}
+	return out, nil
+}
+
+// newline adds a newline, prefix, and indent for lvl times
+func newline(dst []byte, prefix, indent string, lvl int) []byte {
+	dst = append(dst, '\n')
+	dst = append(dst, prefix...)
+	for i := 0; i < lvl; i++ {
+		dst = append(dst, indent...)
+	}
+	return dst
+}
+
+// btoi returns 1 for true
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
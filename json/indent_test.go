@@ -0,0 +1,67 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+var testIndent = []byte(` { "name" : "Gon\tYié", "age":100, "score": -1.5e2,
+	"empty":{}, "none":[ ], "tags":["a", {"b":[1, 2, [ ]]}, null, true],
+	"sub": {"x": {"y": false}} } `)
+
+func TestIndent(t *testing.T) {
+	var exp bytes.Buffer
+	buf := make(gosl.Buf, 0, 1024)
+
+	t.Run("Compact", func(t *testing.T) {
+		exp.Reset()
+		gosl.Test(t, nil, json.Compact(&exp, testIndent))
+		out, err := goslj.Compact(buf.Reset(), testIndent)
+		gosl.Test(t, nil, err)
+		gosl.Test(t, exp.String(), string(out))
+	})
+
+	t.Run("Indent", func(t *testing.T) {
+		exp.Reset()
+		json.Compact(&exp, testIndent) // encoding/json's Indent keeps empty lines of `[ ]`
+		src := append([]byte(nil), exp.Bytes()...)
+		exp.Reset()
+		gosl.Test(t, nil, json.Indent(&exp, src, "> ", "\t"))
+		out, err := goslj.Indent(buf.Reset(), testIndent, "> ", "\t")
+		gosl.Test(t, nil, err)
+		gosl.Test(t, exp.String(), string(out))
+	})
+
+	t.Run("Append", func(t *testing.T) {
+		out, _ := goslj.Compact(buf.Set("data="), []byte(` [1, 2] `))
+		gosl.Test(t, "data=[1,2]", string(out))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		out, err := goslj.Indent(buf.Set("keep"), []byte(`{"a":[1,2}`), "", "  ")
+		gosl.Test(t, goslj.ErrSyntax, err)
+		gosl.Test(t, "keep", string(out))
+	})
+}
+
+func BenchmarkIndent(b *testing.B) {
+	buf := make(gosl.Buf, 0, 1024)
+	b.Run("Compact", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, _ = goslj.Compact(buf.Reset(), testIndent)
+		}
+	})
+	b.Run("Indent", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, _ = goslj.Indent(buf.Reset(), testIndent, "", "  ")
+		}
+	})
+}
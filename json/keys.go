@@ -0,0 +1,188 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+import "github.com/gonyyi/gosl"
+
+// keys.go
+// Checked mode for the builder. By default, JSON does not track names, so
+// `j.Int("a", 1).Int("a", 2)` will write {"a":1,"a":2}. CheckKeys() tracks
+// keys of each open object, and SortKeys() sorts members when an object is
+// closed, which is useful for golden tests.
+//
+// Keys are kept with FNV-1a hashes in a small fixed array in JSON, so typical
+// objects (up to 16 keys across open objects) don't allocate. All edits are
+// done in the buffer in place.
+//
+// Eg.
+//     j.CheckKeys(goslj.KeyOverwrite).SortKeys(true).Start().
+//         Int("b", 1).Int("a", 2).Int("b", 3).
+//         End() // => {"a":2,"b":3}
+
+// KeyMode is how the builder handles duplicate keys
+type KeyMode uint8
+
+const (
+	KeyAllow     KeyMode = iota // KeyAllow doesn't check (default)
+	KeyReport                   // KeyReport writes duplicates, but counts them (see Duplicates())
+	KeyReject                   // KeyReject drops duplicates and Err() will return ErrDuplicateKey
+	KeyOverwrite                // KeyOverwrite replaces the earlier value in place
+)
+
+// keyEntry is a member of an open object
+type keyEntry struct {
+	hash  uint32
+	start int // start of the key (opening quote)
+	key   int // end of the key (after closing quote)
+	end   int // end of the value, -1 while the value is being written
+	dup   int // index of the earlier entry with the same key, -1 if not a duplicate
+}
+
+// CheckKeys sets how duplicate keys will be handled. This should be set before Start().
+// While checked, streaming (SetOutput) will only flush when there's no open object.
+// Like HTMLSafe(), this is kept after Reset(), but cleared when returned to a Pool.
+func (j *JSON) CheckKeys(mode KeyMode) *JSON {
+	j.keyMode = mode
+	if j.keys == nil {
+		j.keys = j.keysBuf[:0]
+	}
+	return j
+}
+
+// SortKeys will sort members of each object by keys when the object is closed.
+// This should be set before Start().
+func (j *JSON) SortKeys(on bool) *JSON {
+	if on {
+		j.flag |= flagSortKeys
+	} else {
+		j.flag &^= flagSortKeys
+	}
+	if j.keys == nil {
+		j.keys = j.keysBuf[:0]
+	}
+	return j
+}
+
+// Duplicates returns how many duplicate keys were found since Reset().
+// This is counted in all modes except KeyAllow.
+func (j *JSON) Duplicates() int {
+	return j.dups
+}
+
+// tracking returns true if keys need to be tracked
+func (j *JSON) tracking() bool {
+	return j.keyMode != KeyAllow || j.flag&flagSortKeys != 0
+}
+
+// keyAdd will add a key written at start to current level.
+func (j *JSON) keyAdd(name string, start int) {
+	h := uint32(2166136261) // FNV-1a
+	for i := 0; i < len(name); i++ {
+		h = (h ^ uint32(name[i])) * 16777619
+	}
+
+	e := keyEntry{hash: h, start: start, key: len(j.buf) - 1, end: -1, dup: -1}
+	if j.keyMode != KeyAllow {
+		for i := int(j.keyBase[j.depth]); i < len(j.keys); i++ {
+			if k := j.keys[i]; k.hash == h && gosl.BytesEqual(j.buf[k.start:k.key], j.buf[e.start:e.key]) {
+				j.dups++
+				if j.keyMode == KeyReject {
+					j.fail(ErrDuplicateKey)
+				}
+				if j.keyMode != KeyReport {
+					e.dup = i
+				}
+				break
+			}
+		}
+	}
+	j.keys = append(j.keys, e)
+}
+
+// keyDone will finish the last member of current level, and
+// drop or move it if it was a duplicate.
+func (j *JSON) keyDone() {
+	last := len(j.keys) - 1
+	if last < int(j.keyBase[j.depth]) || j.keys[last].end >= 0 {
+		return
+	}
+	e := &j.keys[last]
+	e.end = len(j.buf)
+	if e.dup < 0 {
+		return
+	}
+
+	// a duplicate always has a comma before it
+	if j.keyMode == KeyOverwrite {
+		// buf[o.start:e.end] = [old member][mid][,][new member] --> [new member][mid]
+		o := &j.keys[e.dup]
+		n, m, mid := e.end-e.start, o.end-o.start, e.start-1-o.end
+		seg := j.buf[o.start:e.end]
+		rotate(seg, len(seg)-n) // [new][old][mid][,]
+		copy(seg[n:], seg[n+m:])
+		j.buf = j.buf[:o.start+n+mid]
+
+		delta := n - m
+		o.key, o.end = o.start+(e.key-e.start), o.start+n
+		for i := e.dup + 1; i < last; i++ {
+			j.keys[i].start += delta
+			j.keys[i].key += delta
+			j.keys[i].end += delta
+		}
+	} else { // KeyReject
+		j.buf = j.buf[:e.start-1]
+	}
+	j.keys = j.keys[:last]
+}
+
+// keySort will sort members of current level by keys. All members should be done.
+func (j *JSON) keySort() {
+	keys := j.keys[j.keyBase[j.depth]:]
+	for i := 0; i < len(keys); i++ {
+		min := i
+		for k := i + 1; k < len(keys); k++ {
+			if j.keyLess(keys[k], keys[min]) {
+				min = k
+			}
+		}
+		if min == i {
+			continue
+		}
+		// buf[keys[i].start:keys[min].end] = [members i ~ min-1][,][member min]
+		//                               --> [member min][,][members i ~ min-1]
+		mk := keys[min]
+		b := mk.end - mk.start
+		seg := j.buf[keys[i].start:mk.end]
+		gosl.BytesReverse(seg)
+		gosl.BytesReverse(seg[:b])
+		gosl.BytesReverse(seg[b+1:])
+
+		shift := keys[i].start - mk.start
+		for t := i; t < min; t++ {
+			keys[t].start += b + 1
+			keys[t].key += b + 1
+			keys[t].end += b + 1
+		}
+		mk.start, mk.key, mk.end = mk.start+shift, mk.key+shift, mk.end+shift
+		copy(keys[i+1:min+1], keys[i:min])
+		keys[i] = mk
+	}
+}
+
+// keyLess compares keys without quotes
+func (j *JSON) keyLess(a, b keyEntry) bool {
+	ka, kb := j.buf[a.start+1:a.key-1], j.buf[b.start+1:b.key-1]
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if ka[i] != kb[i] {
+			return ka[i] < kb[i]
+		}
+	}
+	return len(ka) < len(kb)
+}
+
+// rotate will rotate p to the left by k bytes in place. eg. rotate("abcde", 2) => "cdeab"
+func rotate(p []byte, k int) {
+	gosl.BytesReverse(p[:k])
+	gosl.BytesReverse(p[k:])
+	gosl.BytesReverse(p)
+}
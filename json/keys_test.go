@@ -0,0 +1,113 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+func TestJSON_CheckKeys(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+	j := goslj.NewJSON(1024)
+
+	build := func(j *goslj.JSON) *goslj.JSON {
+		return j.Start().
+			Int("a", 1).
+			String("b", "x").
+			Object("a").Int("z", 1).Int("z", 2).EndObject().
+			Int("c", 3).
+			Int("c", 44).
+			End()
+	}
+
+	t.Run("Allow", func(t *testing.T) {
+		buf = buf.Reset()
+		build(j.Reset().CheckKeys(goslj.KeyAllow)).Write(&buf)
+		gosl.Test(t, `{"a":1,"b":"x","a":{"z":1,"z":2},"c":3,"c":44}`, buf.String())
+		gosl.Test(t, 0, j.Duplicates())
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		buf = buf.Reset()
+		build(j.Reset().CheckKeys(goslj.KeyReport)).Write(&buf)
+		gosl.Test(t, `{"a":1,"b":"x","a":{"z":1,"z":2},"c":3,"c":44}`, buf.String())
+		gosl.Test(t, 3, j.Duplicates())
+		gosl.Test(t, nil, j.Err())
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		buf = buf.Reset()
+		build(j.Reset().CheckKeys(goslj.KeyReject)).Write(&buf)
+		gosl.Test(t, `{"a":1,"b":"x","c":3}`, buf.String())
+		gosl.Test(t, 3, j.Duplicates())
+		gosl.Test(t, goslj.ErrDuplicateKey, j.Err())
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		buf = buf.Reset()
+		build(j.Reset().CheckKeys(goslj.KeyOverwrite)).Write(&buf)
+		gosl.Test(t, `{"a":{"z":2},"b":"x","c":44}`, buf.String())
+		gosl.Test(t, 3, j.Duplicates())
+		gosl.Test(t, nil, j.Err())
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		buf = buf.Reset()
+		j.Reset().CheckKeys(goslj.KeyAllow).SortKeys(true).Start().
+			Int("b", 1).
+			Object("a").Int("z", 1).Array("y").Object("").Int("q", 1).Int("p", 2).EndObject().EndArray().EndObject().
+			Int("ab", 3).
+			Int("a b", 4).
+			End().Write(&buf)
+		gosl.Test(t, `{"a":{"y":[{"p":2,"q":1}],"z":1},"a b":4,"ab":3,"b":1}`, buf.String())
+	})
+
+	t.Run("SortOverwrite", func(t *testing.T) {
+		buf = buf.Reset()
+		build(j.Reset().CheckKeys(goslj.KeyOverwrite).SortKeys(true)).Write(&buf)
+		gosl.Test(t, `{"a":{"z":2},"b":"x","c":44}`, buf.String())
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		// with KeyOverwrite and SortKeys, output should be same as encoding/json's map
+		r := rand.New(rand.NewSource(1))
+		keys := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t"}
+		j.Reset().CheckKeys(goslj.KeyOverwrite).SortKeys(true)
+		for n := 0; n < 100; n++ {
+			m := make(map[string]int)
+			j.Reset().Start()
+			for i := 0; i < 30; i++ {
+				k, v := keys[r.Intn(len(keys))], r.Intn(1000)
+				m[k] = v
+				j.Int(k, v)
+			}
+			j.End()
+			exp, _ := json.Marshal(m)
+			gosl.Test(t, string(exp), string(j.Bytes()))
+		}
+	})
+
+	j.CheckKeys(goslj.KeyAllow).SortKeys(false)
+}
+
+func BenchmarkJSON_CheckKeys(b *testing.B) {
+	j := goslj.NewJSON(1024).CheckKeys(goslj.KeyOverwrite).SortKeys(true)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		j.Reset().Start().
+			String("name", "Gon Yi").
+			Int("age", 100).
+			Object("address").
+			String("state", "arkansas").
+			String("city", "conway").
+			Int("zip", 72034).
+			EndObject().
+			Int("age", 101).
+			End().Write(gosl.Discard)
+	}
+}
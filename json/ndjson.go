@@ -0,0 +1,192 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+import "github.com/gonyyi/gosl"
+
+// ndjson.go
+// NDJSON (newline delimited JSON, aka JSON Lines) writer and reader.
+//
+// Writing:
+//     nw := goslj.NewNDJSONWriter(os.Stdout)
+//     j := goslj.NewJSON(1024)
+//     for _, v := range items {
+//         nw.WriteJSON(j.Reset().Start().String("name", v.Name).End())
+//     }
+//
+// Reading:
+//     nr := goslj.NewNDJSONReader(f)
+//     defer nr.Close() // returns the buffer to gosl's global buffer pool
+//     var s goslj.Scanner
+//     for nr.Scan(&s) {
+//         for tok, ok := s.Next(); ok; tok, ok = s.Next() { ... }
+//     }
+//     if nr.Err() != nil { ... }
+
+// NewNDJSONWriter will return NDJSONWriter that writes to w.
+func NewNDJSONWriter(w gosl.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// NDJSONWriter writes one JSON per line.
+type NDJSONWriter struct {
+	w     gosl.Writer
+	count int
+}
+
+// Count returns how many lines were written
+func (n *NDJSONWriter) Count() int {
+	return n.count
+}
+
+// WriteJSON will write j followed by a newline in a single write.
+// j should be completed with End(), and not be in streaming mode (SetOutput).
+// If j has an error, it will not be written and the error will be returned.
+func (n *NDJSONWriter) WriteJSON(j *JSON) error {
+	if j.err != nil {
+		return j.err
+	}
+	return n.write(j.buf)
+}
+
+// Write will write p as a line. p should be a single JSON without a newline.
+// This meets gosl.Writer interface.
+func (n *NDJSONWriter) Write(p []byte) (int, error) {
+	buf := gosl.GetBuffer()
+	buf.Buf = append(buf.Buf, p...)
+	err := n.write(buf.Buf)
+	gosl.PutBuffer(buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write appends a newline to p temporarily, and writes it.
+func (n *NDJSONWriter) write(p []byte) error {
+	p = append(p, '\n')
+	w, err := n.w.Write(p)
+	if err == nil && w != len(p) {
+		err = ErrShortWrite
+	}
+	if err == nil {
+		n.count++
+	}
+	return err
+}
+
+// NewNDJSONReader will return NDJSONReader that reads from r.
+// Its buffer is from gosl's global buffer pool; Close() will return it.
+func NewNDJSONReader(r gosl.Reader) *NDJSONReader {
+	buf := gosl.GetBuffer()
+	return &NDJSONReader{
+		r:   r,
+		buf: buf.Buf[:0],
+		release: func(b gosl.Buf) {
+			buf.Buf = b
+			gosl.PutBuffer(buf)
+		},
+	}
+}
+
+// NDJSONReader splits lines from a Reader. Lines are not copied, and
+// a line is only valid until next call of Next() or Scan().
+// If a line is longer than the buffer, the buffer will grow.
+type NDJSONReader struct {
+	r       gosl.Reader
+	buf     gosl.Buf
+	start   int // buf[start:] is not consumed yet
+	line    int
+	eof     bool
+	err     error
+	release func(gosl.Buf)
+}
+
+// Line returns the line number of the last line returned. (1-based, includes empty lines)
+func (n *NDJSONReader) Line() int {
+	return n.line
+}
+
+// Err returns the first read error other than EOF
+func (n *NDJSONReader) Err() error {
+	return n.err
+}
+
+// Close will return the buffer to the pool. After this, Next() and Scan() will return false.
+func (n *NDJSONReader) Close() error {
+	if n.release != nil {
+		n.release(n.buf)
+		n.release, n.buf = nil, nil
+	}
+	n.start, n.eof = 0, true
+	return nil
+}
+
+// Scan will read next line and initialize the scanner s with it.
+func (n *NDJSONReader) Scan(s *Scanner) bool {
+	line, ok := n.Next()
+	if ok {
+		s.Init(line)
+	}
+	return ok
+}
+
+// Next returns next non-empty line without a newline.
+func (n *NDJSONReader) Next() (line []byte, ok bool) {
+	for {
+		if line, ok = n.next(); !ok {
+			return nil, false
+		}
+		n.line++
+		// trim whitespaces including '\r' of "\r\n"
+		for len(line) > 0 && isSpace(line[len(line)-1]) {
+			line = line[:len(line)-1]
+		}
+		for len(line) > 0 && isSpace(line[0]) {
+			line = line[1:]
+		}
+		if len(line) > 0 {
+			return line, true
+		}
+	}
+}
+
+// next returns next line including empty ones
+func (n *NDJSONReader) next() (line []byte, ok bool) {
+	for {
+		if i := gosl.BytesIndex(n.buf[n.start:], '\n'); i >= 0 {
+			line = n.buf[n.start : n.start+i]
+			n.start += i + 1
+			return line, true
+		}
+		if n.eof || n.release == nil {
+			if n.start < len(n.buf) { // last line without a newline
+				line = n.buf[n.start:]
+				n.start = len(n.buf)
+				return line, true
+			}
+			return nil, false
+		}
+
+		// move unconsumed data to the front, and grow if the buffer is full
+		n.buf = n.buf[:copy(n.buf, n.buf[n.start:])]
+		n.start = 0
+		if len(n.buf) == cap(n.buf) {
+			n.buf = append(n.buf, 0)[:len(n.buf)]
+		}
+
+		m, err := n.r.Read(n.buf[len(n.buf):cap(n.buf)])
+		n.buf = n.buf[:len(n.buf)+m]
+		if err != nil {
+			n.eof = true
+			if !isEOF(err) {
+				n.err = err
+			}
+		}
+	}
+}
+
+// isEOF checks for gosl.EOF, and also io.EOF without importing io.
+func isEOF(err error) bool {
+	return gosl.IsError(err, gosl.EOF) || err.Error() == "EOF"
+}
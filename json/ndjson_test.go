@@ -0,0 +1,115 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+func TestNDJSON(t *testing.T) {
+	buf := make(gosl.Buf, 0, 1024)
+
+	t.Run("Writer", func(t *testing.T) {
+		nw := goslj.NewNDJSONWriter(&buf)
+		j := goslj.NewJSON(1024)
+		for i := 0; i < 3; i++ {
+			gosl.Test(t, nil, nw.WriteJSON(j.Reset().Start().Int("id", i).End()))
+		}
+		nw.Write([]byte(`{"raw":true}`))
+		gosl.Test(t, goslj.ErrMismatch, nw.WriteJSON(j.Reset().EndArray()))
+		gosl.Test(t, 4, nw.Count())
+		gosl.Test(t, "{\"id\":0}\n{\"id\":1}\n{\"id\":2}\n{\"raw\":true}\n", buf.String())
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		long := strings.Repeat("x", 5000) // longer than global buffer size
+		in := "{\"id\":0}\r\n\n  \n{\"id\":1}\n{\"s\":\"" + long + "\"}\n{\"id\":3}"
+
+		for _, r := range []gosl.Reader{strings.NewReader(in), iotest.OneByteReader(strings.NewReader(in))} {
+			nr := goslj.NewNDJSONReader(r)
+			var s goslj.Scanner
+			var ids []int
+			for nr.Scan(&s) {
+				if tok, ok := s.Next(); ok && tok.Type == goslj.TypeObject {
+					s.Next() // key
+					tok, _ = s.Next()
+					if i, ok := tok.Int(); ok {
+						ids = append(ids, i)
+					} else {
+						gosl.Test(t, 5000, len(tok.Value))
+						ids = append(ids, -1)
+					}
+				}
+			}
+			gosl.Test(t, nil, nr.Err())
+			gosl.Test(t, "[0 1 -1 3]", sprintInts(ids))
+			gosl.Test(t, 6, nr.Line())
+			nr.Close()
+		}
+
+		// closed in the middle: no more lines, and no panic
+		nr := goslj.NewNDJSONReader(strings.NewReader(in))
+		_, ok := nr.Next()
+		gosl.Test(t, true, ok)
+		nr.Close()
+		_, ok = nr.Next()
+		gosl.Test(t, false, ok)
+		gosl.Test(t, nil, nr.Err())
+	})
+
+	t.Run("ReaderError", func(t *testing.T) {
+		e := errors.New("broken")
+		nr := goslj.NewNDJSONReader(iotest.TimeoutReader(strings.NewReader("{}\n{}\n")))
+		_, ok := nr.Next()
+		gosl.Test(t, true, ok)
+		for ok {
+			_, ok = nr.Next()
+		}
+		gosl.Test(t, iotest.ErrTimeout, nr.Err())
+
+		nr = goslj.NewNDJSONReader(iotest.ErrReader(e))
+		_, ok = nr.Next()
+		gosl.Test(t, false, ok)
+		gosl.Test(t, e, nr.Err())
+		nr.Close()
+	})
+}
+
+func sprintInts(a []int) string {
+	buf := make(gosl.Buf, 0, 64).WriteBytes('[')
+	for i, v := range a {
+		if i > 0 {
+			buf = buf.WriteBytes(' ')
+		}
+		buf = buf.WriteInt(v)
+	}
+	return buf.WriteBytes(']').String()
+}
+
+func BenchmarkNDJSON(b *testing.B) {
+	in := strings.Repeat("{\"name\":\"Gon\",\"age\":100}\n", 100)
+	r := strings.NewReader(in)
+	nr := goslj.NewNDJSONReader(r)
+	defer nr.Close()
+	var s goslj.Scanner
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !nr.Scan(&s) {
+			b.StopTimer()
+			r.Reset(in)
+			nr.Close()
+			nr = goslj.NewNDJSONReader(r)
+			b.StartTimer()
+			continue
+		}
+		for _, ok := s.Next(); ok; _, ok = s.Next() {
+		}
+	}
+}
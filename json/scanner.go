@@ -0,0 +1,543 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+import "github.com/gonyyi/gosl"
+
+// scanner.go
+// Scanner is a zero allocation pull tokenizer for JSON. It does not build any
+// struct or map, instead each call of Next() returns a Token that points back
+// into the original []byte. Strings are kept as-is (still escaped) until the
+// caller asks them to be unescaped into a buffer with Token.Unescape().
+//
+// Usage:
+//     var s goslj.Scanner
+//     s.Init(data)
+//     for tok, ok := s.Next(); ok; tok, ok = s.Next() {
+//         switch tok.Type {
+//         case goslj.TypeKey:
+//             buf = tok.Unescape(buf.Reset())
+//         case goslj.TypeNumber:
+//             n, _ := tok.Int()
+//         }
+//     }
+//     if err := s.Err(); err != nil {
+//         println("error at", s.Offset())
+//     }
+
+// Type is a type of JSON token or value
+type Type uint8
+
+const (
+	TypeNone      Type = iota // TypeNone for no token
+	TypeNull                  // TypeNull for null
+	TypeBool                  // TypeBool for true or false
+	TypeNumber                // TypeNumber for numbers
+	TypeString                // TypeString for strings
+	TypeObject                // TypeObject for beginning of an object, `{`
+	TypeArray                 // TypeArray for beginning of an array, `[`
+	TypeKey                   // TypeKey for a name of object member
+	TypeObjectEnd             // TypeObjectEnd for end of an object, `}`
+	TypeArrayEnd              // TypeArrayEnd for end of an array, `]`
+)
+
+// scanner states: what the scanner expects next
+const (
+	scanValue      uint8 = iota // a value (top level, after ':', or after ',' in an array)
+	scanFirstKey                // after '{': a key or '}'
+	scanKey                     // after ',' in an object: a key
+	scanColon                   // after a key: ':'
+	scanFirstValue              // after '[': a value or ']'
+	scanNext                    // after a value: ',' or a closing bracket
+	scanEnd                     // top level value is complete
+)
+
+// Token is a piece of JSON returned by Scanner.Next().
+// Value points to the original data; for TypeKey and TypeString,
+// Value will not include quotes and escapes are not resolved.
+type Token struct {
+	Type    Type
+	Value   []byte
+	Offset  int  // byte offset of the token in the data
+	escaped bool // true when Value has backslash escape
+}
+
+// Bool returns true if the token is `true`
+func (t Token) Bool() bool {
+	return t.Type == TypeBool && len(t.Value) == 4
+}
+
+// Int will convert TypeNumber token to an integer.
+// If the number has a fraction or an exponent, or too big, ok will be false.
+func (t Token) Int() (i int, ok bool) {
+	if t.Type != TypeNumber {
+		return 0, false
+	}
+	return parseInt(t.Value)
+}
+
+// Float will convert TypeNumber token to a float64.
+func (t Token) Float() (f float64, ok bool) {
+	if t.Type != TypeNumber {
+		return 0, false
+	}
+	return parseFloat(t.Value)
+}
+
+// Unescape will append unescaped TypeKey or TypeString value to dst.
+// For other types, raw value will be appended.
+func (t Token) Unescape(dst gosl.Buf) gosl.Buf {
+	if !t.escaped {
+		return append(dst, t.Value...)
+	}
+	return Unescape(dst, t.Value)
+}
+
+// Scanner is a JSON tokenizer. Zero value is not usable until Init() is called.
+type Scanner struct {
+	data  []byte
+	pos   int
+	state uint8
+	depth int
+	stack [MaxDepth]byte // '{' or '['
+	err   error
+}
+
+// Init will set the data to be scanned and reset the scanner.
+func (s *Scanner) Init(data []byte) *Scanner {
+	s.data = data
+	s.pos = 0
+	s.state = scanValue
+	s.depth = 0
+	s.err = nil
+	return s
+}
+
+// Err returns an error if scanning was stopped by an error. Nil if completed or in progress.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Offset returns current byte offset. When Err() is not nil, this is where the error was found.
+func (s *Scanner) Offset() int {
+	return s.pos
+}
+
+// Depth returns current nesting depth
+func (s *Scanner) Depth() int {
+	return s.depth
+}
+
+// Next returns next token. When there's no more token or an error occurred, ok will be false.
+func (s *Scanner) Next() (tok Token, ok bool) {
+	if s.err != nil {
+		return tok, false
+	}
+
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			if s.state == scanEnd {
+				return tok, false
+			}
+			return tok, s.fail(ErrUnexpectedEnd)
+		}
+
+		c := s.data[s.pos]
+		switch s.state {
+		case scanEnd: // only whitespaces are allowed after top level value
+			return tok, s.fail(ErrSyntax)
+		case scanColon:
+			if c != ':' {
+				return tok, s.fail(ErrSyntax)
+			}
+			s.pos++
+			s.state = scanValue
+			continue
+		case scanNext:
+			if c == ',' {
+				s.pos++
+				if s.stack[s.depth-1] == '{' {
+					s.state = scanKey
+				} else {
+					s.state = scanValue
+				}
+				continue
+			}
+			return s.close(c)
+		case scanFirstKey, scanKey:
+			if c == '}' && s.state == scanFirstKey {
+				return s.close(c)
+			}
+			if c != '"' {
+				return tok, s.fail(ErrSyntax)
+			}
+			if tok, ok = s.string(); ok {
+				tok.Type = TypeKey
+				s.state = scanColon
+			}
+			return tok, ok
+		case scanFirstValue:
+			if c == ']' {
+				return s.close(c)
+			}
+		}
+		return s.value(c)
+	}
+}
+
+// Skip will skip current object or array, including all nested values.
+// This should be called right after Next() returns TypeObject or TypeArray.
+// It returns a complete raw value including brackets.
+func (s *Scanner) Skip(start Token) (raw []byte, ok bool) {
+	if start.Type != TypeObject && start.Type != TypeArray {
+		return start.Value, true
+	}
+	depth := s.depth - 1
+	for s.depth > depth {
+		if _, ok = s.Next(); !ok {
+			return nil, false
+		}
+	}
+	return s.data[start.Offset:s.pos], true
+}
+
+// value reads a value starting with c
+func (s *Scanner) value(c byte) (tok Token, ok bool) {
+	tok.Offset = s.pos
+	switch c {
+	case '{', '[':
+		if s.depth == MaxDepth {
+			return tok, s.fail(ErrTooDeep)
+		}
+		s.stack[s.depth] = c
+		s.depth++
+		s.pos++
+		if c == '{' {
+			tok.Type, s.state = TypeObject, scanFirstKey
+		} else {
+			tok.Type, s.state = TypeArray, scanFirstValue
+		}
+		tok.Value = s.data[tok.Offset:s.pos]
+		return tok, true
+	case '"':
+		if tok, ok = s.string(); !ok {
+			return tok, false
+		}
+		tok.Type = TypeString
+	case 't':
+		tok, ok = s.literal("true", TypeBool)
+	case 'f':
+		tok, ok = s.literal("false", TypeBool)
+	case 'n':
+		tok, ok = s.literal("null", TypeNull)
+	default:
+		n := scanNumber(s.data, s.pos)
+		if n == s.pos {
+			return tok, s.fail(ErrSyntax)
+		}
+		tok.Type, tok.Value, ok = TypeNumber, s.data[s.pos:n], true
+		s.pos = n
+	}
+	if ok {
+		s.afterValue()
+	}
+	return tok, ok
+}
+
+// close handles closing bracket c
+func (s *Scanner) close(c byte) (tok Token, ok bool) {
+	if s.depth == 0 {
+		return tok, s.fail(ErrSyntax)
+	}
+	switch {
+	case c == '}' && s.stack[s.depth-1] == '{':
+		tok.Type = TypeObjectEnd
+	case c == ']' && s.stack[s.depth-1] == '[':
+		tok.Type = TypeArrayEnd
+	default:
+		return tok, s.fail(ErrSyntax)
+	}
+	tok.Offset = s.pos
+	s.pos++
+	tok.Value = s.data[tok.Offset:s.pos]
+	s.depth--
+	s.afterValue()
+	return tok, true
+}
+
+// afterValue updates the state after a value is read
+func (s *Scanner) afterValue() {
+	if s.depth == 0 {
+		s.state = scanEnd
+		return
+	}
+	s.state = scanNext
+}
+
+// string reads a quoted string. Returned token's value won't include quotes.
+func (s *Scanner) string() (tok Token, ok bool) {
+	tok.Offset = s.pos
+	end, escaped, err := scanString(s.data, s.pos)
+	if err != nil {
+		s.pos = end
+		return tok, s.fail(err)
+	}
+	tok.Value = s.data[s.pos+1 : end-1]
+	tok.escaped = escaped
+	s.pos = end
+	return tok, true
+}
+
+// literal reads true, false, and null
+func (s *Scanner) literal(lit string, typ Type) (tok Token, ok bool) {
+	if len(s.data)-s.pos < len(lit) {
+		return tok, s.fail(ErrUnexpectedEnd)
+	}
+	if string(s.data[s.pos:s.pos+len(lit)]) != lit {
+		return tok, s.fail(ErrSyntax)
+	}
+	tok.Type, tok.Offset = typ, s.pos
+	tok.Value = s.data[s.pos : s.pos+len(lit)]
+	s.pos += len(lit)
+	return tok, true
+}
+
+// skipSpace moves the position to next non-whitespace
+func (s *Scanner) skipSpace() {
+	for s.pos < len(s.data) && isSpace(s.data[s.pos]) {
+		s.pos++
+	}
+}
+
+// fail sets the error and always returns false
+func (s *Scanner) fail(err error) bool {
+	s.err = err
+	return false
+}
+
+// ********************************************************************************
+// Scanning and parsing helpers
+// ********************************************************************************
+
+// isSpace returns true for JSON whitespaces
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// scanString takes p and index i of an opening quote, and returns index right after closing quote.
+// When failed, end will be an index where the problem was found.
+func scanString(p []byte, i int) (end int, escaped bool, err error) {
+	for i++; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '"':
+			return i + 1, escaped, nil
+		case c == '\\':
+			escaped = true
+			if i+1 >= len(p) {
+				return len(p), escaped, ErrUnexpectedEnd
+			}
+			i++
+			if p[i] == 'u' {
+				if _, ok := hex4(p[i+1:]); !ok {
+					return i, escaped, ErrInvalidEscape
+				}
+				i += 4
+			} else if stringUnescapes[p[i]] == 0 {
+				return i, escaped, ErrInvalidEscape
+			}
+		case c < 0x20:
+			return i, escaped, ErrControlChar
+		}
+	}
+	return len(p), escaped, ErrUnexpectedEnd
+}
+
+// scanNumber takes p and index i where a number starts, and returns an index right after the number.
+// If it's not a valid number, it will return i.
+func scanNumber(p []byte, i int) int {
+	start := i
+	if i < len(p) && p[i] == '-' {
+		i++
+	}
+	// integer part: either a single 0 or 1-9 followed by digits
+	switch {
+	case i < len(p) && p[i] == '0':
+		i++
+	case i < len(p) && '1' <= p[i] && p[i] <= '9':
+		i = scanDigits(p, i)
+	default:
+		return start
+	}
+	// fraction
+	if i < len(p) && p[i] == '.' {
+		if n := scanDigits(p, i+1); n > i+1 {
+			i = n
+		} else {
+			return start
+		}
+	}
+	// exponent
+	if i < len(p) && (p[i] == 'e' || p[i] == 'E') {
+		j := i + 1
+		if j < len(p) && (p[j] == '+' || p[j] == '-') {
+			j++
+		}
+		if n := scanDigits(p, j); n > j {
+			i = n
+		} else {
+			return start
+		}
+	}
+	return i
+}
+
+// scanDigits returns index of the first non-digit from i
+func scanDigits(p []byte, i int) int {
+	for i < len(p) && '0' <= p[i] && p[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// parseInt is gosl.Atoi for JSON integer in []byte.
+func parseInt(p []byte) (num int, ok bool) {
+	if len(p) == 0 || scanNumber(p, 0) != len(p) {
+		return 0, false
+	}
+	neg := p[0] == '-'
+	if neg {
+		p = p[1:]
+	}
+	const maxInt = int(^uint(0) >> 1)
+	for _, c := range p {
+		c -= '0'
+		if c > 9 { // fraction or exponent
+			return 0, false
+		}
+		if num > (maxInt-int(c))/10 {
+			return 0, false
+		}
+		num = num*10 + int(c)
+	}
+	if neg {
+		num = -num
+	}
+	return num, true
+}
+
+// pow10 is a table of exactly representable powers of 10 in float64
+var pow10 = [...]float64{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11,
+	1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22}
+
+// parseFloat converts JSON number p to float64.
+// Like gosl.BytesAppendFloat, this is simple and may lose precision at the last digit.
+func parseFloat(p []byte) (f float64, ok bool) {
+	if len(p) == 0 || scanNumber(p, 0) != len(p) {
+		return 0, false
+	}
+	neg := p[0] == '-'
+	if neg {
+		p = p[1:]
+	}
+
+	exp, dot := 0, false
+	i := 0
+	for ; i < len(p) && p[i] != 'e' && p[i] != 'E'; i++ {
+		if p[i] == '.' {
+			dot = true
+			continue
+		}
+		if f < 1e18 {
+			f = f*10 + float64(p[i]-'0')
+			if dot {
+				exp--
+			}
+		} else if !dot {
+			exp++ // digits beyond float64 precision are dropped, but keep the scale
+		}
+	}
+	if i < len(p) { // exponent
+		i++
+		eneg := p[i] == '-'
+		if p[i] == '+' || p[i] == '-' {
+			i++
+		}
+		e := 0
+		for ; i < len(p) && e < 10000; i++ {
+			e = e*10 + int(p[i]-'0')
+		}
+		if eneg {
+			e = -e
+		}
+		exp += e
+	}
+
+	for exp > 0 {
+		n := exp
+		if n >= len(pow10) {
+			n = len(pow10) - 1
+		}
+		f *= pow10[n]
+		exp -= n
+	}
+	for exp < 0 {
+		n := -exp
+		if n >= len(pow10) {
+			n = len(pow10) - 1
+		}
+		f /= pow10[n]
+		exp += n
+	}
+	if neg {
+		f = -f
+	}
+	return f, true
+}
+
+// stringUnescapes is stringEscapes in reverse, plus `\/` which is allowed by JSON but never written by goslj.
+var stringUnescapes = func() (out [256]byte) {
+	for k, v := range stringEscapes {
+		if v != 0 {
+			out[v] = byte(k)
+		}
+	}
+	out['/'] = '/'
+	return out
+}()
+
+// Unescape will append unescaped JSON string src (without quotes) to dst.
+// Invalid escapes will be kept as-is, and invalid `\u` surrogates become U+FFFD.
+func Unescape(dst gosl.Buf, src []byte) gosl.Buf {
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c != '\\' || i+1 == len(src) {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		if src[i] != 'u' {
+			if u := stringUnescapes[src[i]]; u != 0 {
+				dst = append(dst, u)
+			} else {
+				dst = append(dst, '\\', src[i])
+			}
+			continue
+		}
+		r, ok := hex4(src[i+1:])
+		if !ok {
+			dst = append(dst, '\\', 'u')
+			continue
+		}
+		i += 4
+		// surrogate pair: 😀
+		if 0xD800 <= r && r < 0xDC00 && i+6 < len(src) && src[i+1] == '\\' && src[i+2] == 'u' {
+			if r2, ok := hex4(src[i+3:]); ok && 0xDC00 <= r2 && r2 < 0xE000 {
+				r = (r-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+				i += 6
+			}
+		}
+		dst = appendRune(dst, r)
+	}
+	return dst
+}
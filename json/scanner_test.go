@@ -0,0 +1,116 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+var testScan = []byte(`{"name":"Gon\tYi","age":100,"score":-1.5e2,"ok":true,"nil":null,` +
+	`"tags":["a","bé😀"],"sub":{"x":[]}}`)
+
+func TestScanner(t *testing.T) {
+	var s goslj.Scanner
+	buf := make(gosl.Buf, 0, 1024)
+
+	s.Init(testScan)
+	for tok, ok := s.Next(); ok; tok, ok = s.Next() {
+		buf = buf.WriteInt(int(tok.Type)).WriteBytes('=')
+		buf = tok.Unescape(buf).WriteBytes(' ')
+	}
+	gosl.Test(t, nil, s.Err())
+	gosl.Test(t, "5={ 7=name 4=Gon\tYi 7=age 3=100 7=score 3=-1.5e2 7=ok 2=true 7=nil 1=null "+
+		"7=tags 6=[ 4=a 4=bé😀 9=] 7=sub 5={ 7=x 6=[ 9=] 8=} 8=} ", buf.String())
+
+	t.Run("Int/Float/Bool", func(t *testing.T) {
+		s.Init([]byte(`[100,-1.5e2,0.25,true,false,12.0,99999999999999999999]`))
+		s.Next() // [
+		tok, _ := s.Next()
+		i, ok := tok.Int()
+		gosl.Test(t, true, ok)
+		gosl.Test(t, 100, i)
+
+		tok, _ = s.Next()
+		f, ok := tok.Float()
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, f == -150.0)
+		_, ok = tok.Int()
+		gosl.Test(t, false, ok)
+
+		tok, _ = s.Next()
+		f, _ = tok.Float()
+		gosl.Test(t, true, f == 0.25)
+
+		tok, _ = s.Next()
+		gosl.Test(t, true, tok.Bool())
+		tok, _ = s.Next()
+		gosl.Test(t, false, tok.Bool())
+
+		tok, _ = s.Next()
+		_, ok = tok.Int()
+		gosl.Test(t, false, ok)
+
+		tok, _ = s.Next() // overflow
+		_, ok = tok.Int()
+		gosl.Test(t, false, ok)
+	})
+
+	t.Run("Unescape", func(t *testing.T) {
+		buf = goslj.Unescape(buf.Reset(), []byte(`a\"\\\/\n\u00e9\ud83d\ude00\ud83d`))
+		gosl.Test(t, "a\"\\/\né😀\uFFFD", buf.String())
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		s.Init([]byte(`{"a":{"b":[1,{"c":2}]},"d":3}`))
+		s.Next()               // {
+		s.Next()               // a
+		tok, _ := s.Next()     // {
+		raw, ok := s.Skip(tok) // skip {"b":[1,{"c":2}]}
+		gosl.Test(t, true, ok)
+		gosl.Test(t, `{"b":[1,{"c":2}]}`, string(raw))
+		tok, _ = s.Next()
+		gosl.Test(t, "d", string(tok.Value))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		tests := []struct {
+			in     string
+			err    error
+			offset int
+		}{
+			{`{"a":1,}`, goslj.ErrSyntax, 7},
+			{`{"a":1]`, goslj.ErrSyntax, 6},
+			{`{"a" 1}`, goslj.ErrSyntax, 5},
+			{`[1,2`, goslj.ErrUnexpectedEnd, 4},
+			{`["a\x"]`, goslj.ErrInvalidEscape, 4},
+			{"[\"a\nb\"]", goslj.ErrControlChar, 3},
+			{`[01]`, goslj.ErrSyntax, 2},
+			{`[tru]`, goslj.ErrSyntax, 1},
+			{`{} {}`, goslj.ErrSyntax, 3},
+			{``, goslj.ErrUnexpectedEnd, 0},
+		}
+		for _, v := range tests {
+			s.Init([]byte(v.in))
+			for _, ok := s.Next(); ok; _, ok = s.Next() {
+			}
+			gosl.Test(t, v.err, s.Err())
+			gosl.Test(t, v.offset, s.Offset())
+		}
+	})
+}
+
+func BenchmarkScanner(b *testing.B) {
+	var s goslj.Scanner
+	buf := make(gosl.Buf, 0, 1024)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Init(testScan)
+		for tok, ok := s.Next(); ok; tok, ok = s.Next() {
+			buf = tok.Unescape(buf.Reset())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+// utf8.go
+// Minimal UTF-8 helpers so goslj does not need to import unicode/utf8.
+
+// runeError is the Unicode replacement character (U+FFFD)
+const runeError = '�'
+
+// appendRune will append UTF-8 encoded rune r to dst.
+// Surrogate halves and out of range values will be written as runeError.
+func appendRune(dst []byte, r rune) []byte {
+	switch {
+	case r < 0:
+		r = runeError
+	case r < 0x80:
+		return append(dst, byte(r))
+	case r < 0x800:
+		return append(dst, 0xC0|byte(r>>6), 0x80|byte(r)&0x3F)
+	case 0xD800 <= r && r <= 0xDFFF, r > 0x10FFFF:
+		r = runeError
+	case r >= 0x10000:
+		return append(dst, 0xF0|byte(r>>18), 0x80|byte(r>>12)&0x3F, 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+	}
+	return append(dst, 0xE0|byte(r>>12), 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+}
+
+// decodeRune will decode a UTF-8 rune from s starting at index i.
+// For invalid UTF-8, it returns (runeError, 1) just like utf8.DecodeRuneInString.
+func decodeRune(s string, i int) (r rune, size int) {
+	if r, size = runeStart(s[i]); size < 2 {
+		return r, 1
+	}
+	if len(s)-i < size {
+		return runeError, 1
+	}
+	for k := 1; k < size; k++ {
+		cc := s[i+k]
+		if cc&0xC0 != 0x80 {
+			return runeError, 1
+		}
+		r = r<<6 | rune(cc&0x3F)
+	}
+	return runeEnd(r, size)
+}
+
+// decodeRuneBytes is decodeRune for a byte slice.
+func decodeRuneBytes(p []byte, i int) (r rune, size int) {
+	if r, size = runeStart(p[i]); size < 2 {
+		return r, 1
+	}
+	if len(p)-i < size {
+		return runeError, 1
+	}
+	for k := 1; k < size; k++ {
+		cc := p[i+k]
+		if cc&0xC0 != 0x80 {
+			return runeError, 1
+		}
+		r = r<<6 | rune(cc&0x3F)
+	}
+	return runeEnd(r, size)
+}
+
+// runeStart returns the size of a rune and its bits from the first byte c.
+// For ASCII, it returns (c, 1), and for an invalid first byte, (runeError, 0).
+func runeStart(c byte) (r rune, size int) {
+	switch {
+	case c < 0x80:
+		return rune(c), 1
+	case c < 0xC2: // continuation byte or overlong 2-byte
+		return runeError, 0
+	case c < 0xE0:
+		return rune(c & 0x1F), 2
+	case c < 0xF0:
+		return rune(c & 0x0F), 3
+	case c < 0xF5:
+		return rune(c & 0x07), 4
+	default:
+		return runeError, 0
+	}
+}
+
+// runeEnd checks a decoded rune for overlong encodings, surrogate halves, and out of range.
+func runeEnd(r rune, size int) (rune, int) {
+	if (size == 3 && r < 0x800) || (size == 4 && (r < 0x10000 || r > 0x10FFFF)) || (0xD800 <= r && r <= 0xDFFF) {
+		return runeError, 1
+	}
+	return r, size
+}
+
+// hex4 will read 4 hex digits (as in `\uXXXX`) from p.
+func hex4(p []byte) (r rune, ok bool) {
+	if len(p) < 4 {
+		return 0, false
+	}
+	for i := 0; i < 4; i++ {
+		c := p[i]
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
@@ -0,0 +1,44 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj
+
+// valid.go
+// Valid checks if data is a well-formed JSON without allocation. In addition to
+// what Scanner checks (syntax, escapes, numbers, and MaxDepth), Valid also
+// checks if strings are valid UTF-8.
+//
+// Eg.
+//     if ok, offset, err := goslj.Valid(data); !ok {
+//         println("invalid JSON at", offset, err.Error())
+//     }
+
+// Valid returns true when data is a valid JSON. If not, offset will be the
+// byte offset where the problem was found, and reason will be the error.
+func Valid(data []byte) (ok bool, offset int, reason error) {
+	var s Scanner
+	s.Init(data)
+
+	for tok, ok := s.Next(); ok; tok, ok = s.Next() {
+		if tok.Type == TypeKey || tok.Type == TypeString {
+			if i := invalidUTF8(tok.Value); i >= 0 {
+				return false, tok.Offset + 1 + i, ErrInvalidUTF8 // +1 for the quote
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, s.Offset(), err
+	}
+	return true, len(data), nil
+}
+
+// invalidUTF8 returns an index of the first invalid UTF-8 byte in p, or -1 if p is valid.
+func invalidUTF8(p []byte) int {
+	for i := 0; i < len(p); {
+		r, size := decodeRuneBytes(p, i)
+		if r == runeError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
@@ -0,0 +1,115 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package goslj_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	goslj "github.com/gonyyi/gosl/json"
+)
+
+func TestValid(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		for _, v := range []string{
+			`{}`, `[]`, `0`, `-0.5e+10`, `"aé😀"`, ` null `, `"한글"`,
+			`{"a":[1,{"b":[true,false,null]}],"c":"\\\"\/\b\f\n\r\t"}`,
+		} {
+			ok, offset, err := goslj.Valid([]byte(v))
+			gosl.Test(t, true, ok)
+			gosl.Test(t, len(v), offset)
+			gosl.Test(t, nil, err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		deep := make([]byte, goslj.MaxDepth+1)
+		for i := range deep {
+			deep[i] = '['
+		}
+		for _, v := range []struct {
+			in     string
+			offset int
+			err    error
+		}{
+			{`{"a":1,}`, 7, goslj.ErrSyntax},
+			{`[1.]`, 1, goslj.ErrSyntax},
+			{`[1e]`, 1, goslj.ErrSyntax},
+			{`[-]`, 1, goslj.ErrSyntax},
+			{`["\u12"]`, 3, goslj.ErrInvalidEscape},
+			{`{"a":"b`, 7, goslj.ErrUnexpectedEnd},
+			{"[\"ok\",\"a\xffb\"]", 8, goslj.ErrInvalidUTF8},
+			{"{\"\xc0\xaf\":1}", 2, goslj.ErrInvalidUTF8},
+			{string(deep), goslj.MaxDepth, goslj.ErrTooDeep},
+		} {
+			ok, offset, err := goslj.Valid([]byte(v.in))
+			gosl.Test(t, false, ok)
+			gosl.Test(t, v.err, err)
+			gosl.Test(t, v.offset, offset)
+		}
+	})
+
+	t.Run("Builder", func(t *testing.T) {
+		// every combination of 3 builder calls in an object, a nested object, and an array
+		// should produce a valid JSON.
+		sub := goslj.NewJSON(256).Start().String("x", "y").End()
+		ops := []func(j *goslj.JSON){
+			func(j *goslj.JSON) { j.String("s", "a\"\x00\xff<&> ") },
+			func(j *goslj.JSON) { j.Int("i", -1) },
+			func(j *goslj.JSON) { j.Float("f", math.NaN(), 2).Float("g", -0.5, 3) },
+			func(j *goslj.JSON) {
+				j.Float("x", 1e19, 0).Float("y", -1e300, 3).Float("z", 1.5, 20).
+					FloatArray("xa", 2, 1e19, -9.3e18).Array("xs").AddFloat(1e19, 2).AddFloat(0.25, 255).EndArray()
+			},
+			func(j *goslj.JSON) { j.Bool("b", false).Null("n") },
+			func(j *goslj.JSON) { j.Raw("r", []byte(`{"raw":[1]}`)).Raw("e", nil) },
+			func(j *goslj.JSON) { j.Hex("h", []byte{0, 255}).Base64("b64", []byte{1}) },
+			func(j *goslj.JSON) { j.Timestamp("t", 0, false).Timestamp("tf", 0, true) },
+			func(j *goslj.JSON) { j.IntArray("ia").StringArray("sa", "a", "b") },
+			func(j *goslj.JSON) { j.FloatArray("fa", 1, 1, 2).BoolArray("ba", true) },
+			func(j *goslj.JSON) { j.HexArray("ha").Base64Array("b64a", nil).TimestampArray("ta", true, 0) },
+			func(j *goslj.JSON) { j.RawArray("ra", []byte(`1`)).Sub("sub", sub) },
+			func(j *goslj.JSON) { j.Object("o").EndObject().Array("a").EndArray() },
+			func(j *goslj.JSON) { j.Array("a").AddInt(1).AddString("s").AddNull().AddBool(true).EndArray() },
+			func(j *goslj.JSON) {
+				j.Array("a").AddFloat(1.5, 1).AddRaw(nil).Object("").Int("i", 1).EndObject().EndArray()
+			},
+		}
+		contexts := []func(j *goslj.JSON){
+			func(j *goslj.JSON) { j.Start() },
+			func(j *goslj.JSON) { j.Start().Object("obj") },
+			func(j *goslj.JSON) { j.Start().Array("arr") },
+			func(j *goslj.JSON) { j.StartArray() },
+		}
+
+		j := goslj.NewJSON(1024)
+		for _, mode := range []goslj.KeyMode{goslj.KeyAllow, goslj.KeyOverwrite} {
+			j.CheckKeys(mode).SortKeys(mode != goslj.KeyAllow)
+			for _, ctx := range contexts {
+				for _, a := range ops {
+					for _, b := range ops {
+						for _, c := range ops {
+							ctx(j.Reset())
+							a(j)
+							b(j)
+							c(j)
+							j.End()
+							ok, offset, err := goslj.Valid(j.Bytes())
+							if !ok || j.Err() != nil {
+								t.Fatalf("invalid JSON at %d (%v/%v): %s", offset, err, j.Err(), j.Bytes())
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkValid(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		goslj.Valid(testScan)
+	}
+}
@@ -0,0 +1,182 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// batch.go
+// Batcher collects items added concurrently, and flushes them together when the batch
+// has size items, or delay has passed since the first item of the batch was added.
+// Each flush runs as a job of the Limiter, so flushes share the same workers.
+//
+// The flush function can set an error for each item in errs. If it returns an error,
+// the error goes to every item that doesn't have its own error.
+// Items waiting in a Batcher are not jobs of the Limiter yet, so call Batcher.Close()
+// before Limiter.Wait() when shutting down.
+//
+// Eg.
+//     b := limiter.NewBatcher(l, 100, 10*time.Millisecond, func(items []interface{}, errs []error) error {
+//         return db.InsertMany(items)
+//     })
+//     if err := b.Do(row); err != nil { // waits until the batch is flushed
+//         ...
+//     }
+//     b.Close()
+//     l.Wait()
+
+// FlushFunc flushes items. errs has the same length as items.
+type FlushFunc func(items []interface{}, errs []error) error
+
+// NewBatcher returns a Batcher. If size is less than 1, 1 will be used.
+// If delay is 0 or less, a batch will only be flushed by its size, Flush(), or Close().
+func NewBatcher(l *Limiter, size int, delay time.Duration, flush FlushFunc) *Batcher {
+	if size < 1 {
+		size = 1
+	}
+	return &Batcher{
+		l:     l,
+		mu:    gosl.NewMutex(),
+		size:  size,
+		delay: delay,
+		flush: flush,
+	}
+}
+
+// Batcher groups items into batches.
+type Batcher struct {
+	l      *Limiter
+	mu     gosl.Mutex
+	size   int
+	delay  time.Duration
+	flush  FlushFunc
+	cur    batch       // current batch
+	gen    uint64      // increased for every batch, to ignore a timer of the previous batch
+	timer  *time.Timer // timer of the current batch
+	closed bool
+}
+
+// batch is items and their completion channels
+type batch struct {
+	items []interface{}
+	done  []chan error
+}
+
+// Add adds an item to the current batch. done will receive the result of the item
+// when its batch is flushed. If the Batcher is closed, ok will be false.
+func (b *Batcher) Add(item interface{}) (done <-chan error, ok bool) {
+	ch := make(chan error, 1)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, false
+	}
+	b.cur.items = append(b.cur.items, item)
+	b.cur.done = append(b.cur.done, ch)
+
+	var full batch
+	if len(b.cur.items) >= b.size {
+		full = b.take()
+	} else if len(b.cur.items) == 1 && b.delay > 0 { // first item of a batch
+		gen := b.gen
+		b.timer = time.AfterFunc(b.delay, func() { b.flushGen(gen) })
+	}
+	b.mu.Unlock()
+
+	b.run(full)
+	return ch, true
+}
+
+// Do adds an item, and waits until its batch is flushed.
+func (b *Batcher) Do(item interface{}) error {
+	done, ok := b.Add(item)
+	if !ok {
+		return ErrStopped
+	}
+	return <-done
+}
+
+// Flush flushes the current batch without waiting for its size or delay.
+func (b *Batcher) Flush() {
+	b.mu.Lock()
+	cur := b.take()
+	b.mu.Unlock()
+	b.run(cur)
+}
+
+// Close stops taking items, and flushes the current batch.
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	cur := b.take()
+	b.mu.Unlock()
+	b.run(cur)
+}
+
+// Len returns number of items in the current batch.
+func (b *Batcher) Len() (n int) {
+	b.mu.Lock()
+	n = len(b.cur.items)
+	b.mu.Unlock()
+	return n
+}
+
+// flushGen flushes the current batch if it is still the batch gen. Called by the timer.
+func (b *Batcher) flushGen(gen uint64) {
+	b.mu.Lock()
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+	cur := b.take()
+	b.mu.Unlock()
+	b.run(cur)
+}
+
+// take returns the current batch, and starts a new one. This should be called within a lock.
+func (b *Batcher) take() (cur batch) {
+	cur = b.cur
+	b.cur = batch{}
+	b.gen += 1
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return cur
+}
+
+// run flushes the batch as a job of the limiter. If the job is cancelled,
+// every item gets ErrCancelled.
+func (b *Batcher) run(cur batch) {
+	if len(cur.items) == 0 {
+		return
+	}
+	fail := func(err error) {
+		for _, ch := range cur.done {
+			ch <- err
+		}
+	}
+	ok := b.l.submit(gosl.LvInfo, job{
+		f: func() {
+			errs := make([]error, len(cur.items))
+			var err error
+			if perr := b.l.protect(func() { err = b.flush(cur.items, errs) }); perr != nil {
+				err = perr
+			}
+			for i, ch := range cur.done {
+				if errs[i] == nil {
+					errs[i] = err
+				}
+				ch <- errs[i]
+			}
+		},
+		drop: fail, // cancelled in the queue by Stop(false) or StopAndWait()
+	}, -1)
+	if !ok {
+		fail(ErrStopped)
+	}
+}
@@ -0,0 +1,129 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestBatcher(t *testing.T) {
+	t.Run("Size", func(t *testing.T) {
+		l := limiter.NewLimiter(2, 10)
+		sizes := make(chan int, 10)
+		b := limiter.NewBatcher(l, 3, time.Hour, func(items []interface{}, errs []error) error {
+			sizes <- len(items)
+			return nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				gosl.Test(t, nil, b.Do(i))
+			}(i)
+		}
+		wg.Wait()
+		done, ok := b.Add(6)
+		gosl.Test(t, true, ok)
+		gosl.Test(t, 1, b.Len())
+		b.Close()
+		gosl.Test(t, nil, <-done)
+		l.Wait()
+
+		gosl.Test(t, 3, <-sizes)
+		gosl.Test(t, 3, <-sizes)
+		gosl.Test(t, 1, <-sizes)
+		_, ok = b.Add(7)
+		gosl.Test(t, false, ok)
+		gosl.Test(t, limiter.ErrStopped, b.Do(7))
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 10)
+		batches := make(chan []interface{}, 10)
+		b := limiter.NewBatcher(l, 100, 20*time.Millisecond, func(items []interface{}, errs []error) error {
+			batches <- append([]interface{}(nil), items...)
+			return nil
+		})
+		start := time.Now()
+		d1, _ := b.Add("a")
+		d2, _ := b.Add("b")
+		gosl.Test(t, nil, <-d1)
+		gosl.Test(t, nil, <-d2)
+		gosl.Test(t, true, time.Since(start) >= 20*time.Millisecond)
+		items := <-batches
+		gosl.Test(t, 2, len(items))
+		gosl.Test(t, "a", items[0].(string))
+
+		d3, _ := b.Add("c") // a new batch with its own timer
+		b.Flush()
+		gosl.Test(t, nil, <-d3)
+		gosl.Test(t, 1, len(<-batches))
+		time.Sleep(30 * time.Millisecond) // the timer of "c" won't flush again
+		gosl.Test(t, 0, len(batches))
+		b.Close()
+		l.Wait()
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		e1, e2 := gosl.NewError("item"), gosl.NewError("batch")
+		l := limiter.NewLimiter(1, 10)
+		b := limiter.NewBatcher(l, 3, 0, func(items []interface{}, errs []error) error {
+			if items[0].(int) == 0 {
+				errs[1] = e1
+				return e2
+			}
+			panic("oops")
+		})
+		d0, _ := b.Add(0)
+		d1, _ := b.Add(1)
+		d2, _ := b.Add(2)
+		gosl.Test(t, e2, <-d0)
+		gosl.Test(t, e1, <-d1)
+		gosl.Test(t, e2, <-d2)
+
+		d3, _ := b.Add(3)
+		b.Flush()
+		gosl.Test(t, true, gosl.IsError(<-d3, limiter.ErrPanic))
+
+		l.Stop(true)
+		d4, _ := b.Add(4)
+		b.Flush()
+		gosl.Test(t, limiter.ErrStopped, <-d4)
+		l.Wait()
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		for _, stop := range []func(l *limiter.Limiter){
+			func(l *limiter.Limiter) { l.Stop(false) },
+			func(l *limiter.Limiter) { l.StopAndWait(time.Second) },
+			func(l *limiter.Limiter) { l.Stop(true); l.StopAndWait(time.Second) }, // skipped by the worker
+		} {
+			l := limiter.NewLimiter(1, 10)
+			gate := make(chan struct{})
+			l.Run(func() { <-gate }) // the only worker is busy
+			b := limiter.NewBatcher(l, 1, 0, func(items []interface{}, errs []error) error {
+				t.Error("should have been cancelled")
+				return nil
+			})
+			d, _ := b.Add(1) // flush job waits in the queue
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				close(gate)
+			}()
+			stop(l)
+			l.Wait()
+			gosl.Test(t, limiter.ErrCancelled, <-d)
+			gosl.Test(t, true, l.Close())
+		}
+	})
+}
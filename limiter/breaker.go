@@ -0,0 +1,295 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// breaker.go
+// Breaker is a circuit breaker. While a downstream dependency is failing,
+// it rejects jobs right away instead of letting them wait and fail.
+// - Closed:   jobs run. Too many failures will open it.
+// - Open:     jobs are rejected with ErrBreakerOpen until CoolDown has passed.
+// - HalfOpen: up to Probes jobs run as trials. If all succeed, it closes.
+//             If any of them fails, it opens again.
+//
+// Eg.
+//     b := limiter.NewBreaker(limiter.BreakerConfig{ConsecutiveFailures: 5, CoolDown: 10 * time.Second}, nil)
+//     b.OnStateChange(func(from, to limiter.BreakerState) {
+//         lw.Warn().WriteString("breaker: " + from.String() + " -> " + to.String())
+//     })
+//     if !b.Run(l, callAPI) {
+//         return 503
+//     }
+
+var ErrBreakerOpen = gosl.NewError("limiter: circuit breaker is open")
+
+// BreakerState is a state of Breaker
+type BreakerState uint8
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig is thresholds of Breaker. Failures are counted from when it was closed.
+// If neither ConsecutiveFailures nor FailureRatio is set, ConsecutiveFailures will be 5.
+//
+// Without Interval, FailureRatio is of all results since it was closed: after a long
+// healthy run, it takes many failures to move the ratio. With Interval, only results
+// within the last Interval are counted (in 10 buckets, so results expire Interval/10 at a time).
+type BreakerConfig struct {
+	ConsecutiveFailures int           // opens after this many failures in a row, 0 to disable
+	FailureRatio        float64       // opens when failures/results reaches this (0-1), 0 to disable
+	MinResults          int           // min number of results before FailureRatio is checked
+	Interval            time.Duration // FailureRatio counts results within this, 0 for since closed
+	CoolDown            time.Duration // time to stay open before half-open, default 5 seconds
+	Probes              int           // number of trial jobs in half-open, default 1
+}
+
+// NewBreaker returns a Breaker in the closed state. If clock is nil, SystemClock will be used.
+func NewBreaker(cfg BreakerConfig, clock Clock) *Breaker {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if cfg.ConsecutiveFailures <= 0 && cfg.FailureRatio <= 0 {
+		cfg.ConsecutiveFailures = 5
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 5 * time.Second
+	}
+	if cfg.Probes < 1 {
+		cfg.Probes = 1
+	}
+	return &Breaker{
+		mu:    gosl.NewMutex(),
+		clock: clock,
+		cfg:   cfg,
+	}
+}
+
+// Breaker is a circuit breaker.
+type Breaker struct {
+	mu       gosl.Mutex
+	clock    Clock
+	cfg      BreakerConfig
+	state    BreakerState
+	gen      uint64    // increased on every state change, to ignore results from the previous state
+	openedAt time.Time // when it was opened
+	results  int       // number of results since closed
+	failures int       // number of failures since closed
+	buckets  [breakerBuckets]breakerBucket
+	inRow    int // number of failures in a row
+	inflight int // number of probes running in half-open
+	probed   int // number of probes succeeded in half-open
+	onChange func(from, to BreakerState)
+}
+
+// breakerBuckets is number of buckets in BreakerConfig.Interval
+const breakerBuckets = 10
+
+// breakerBucket is results in a part of BreakerConfig.Interval
+type breakerBucket struct {
+	epoch    int64 // time / bucket width
+	results  int
+	failures int
+}
+
+// OnStateChange sets a callback called when the state changes. It is called outside the lock.
+func (b *Breaker) OnStateChange(f func(from, to BreakerState)) {
+	b.mu.Lock()
+	b.onChange = f
+	b.mu.Unlock()
+}
+
+// State returns the current state. An open breaker becomes half-open when checked after CoolDown.
+func (b *Breaker) State() (state BreakerState) {
+	b.mu.Lock()
+	from := b.state
+	b.coolDown()
+	state = b.state
+	onChange := b.onChange
+	b.mu.Unlock()
+	if onChange != nil && from != state {
+		onChange(from, state)
+	}
+	return state
+}
+
+// Allow checks if a job can run now. If ok, done must be called with the result of the job.
+func (b *Breaker) Allow() (done func(err error), ok bool) {
+	gen, ok := b.acquire()
+	if !ok {
+		return nil, false
+	}
+	return func(err error) { b.report(gen, err) }, true
+}
+
+// Run adds f to the limiter if the breaker allows. When the breaker rejects it, it will be counted
+// as a rejected job of the limiter with ErrBreakerOpen. The result of f (including a panic) is
+// reported to the breaker and to the limiter's OnFinish() hook, but not recorded to Err() or
+// Results() as only jobs added by RunErr() or RunRetry() are.
+func (b *Breaker) Run(l *Limiter, f func() error) (ok bool) {
+	if f == nil || l == nil {
+		return false
+	}
+	gen, ok := b.acquire()
+	if !ok {
+		if l.mu != nil {
+			l.reject(ErrBreakerOpen)
+		}
+		return false
+	}
+
+	ok = l.submit(gosl.LvInfo, job{fe: func() (err error) {
+		err = ErrPanic // stays when f panics
+		defer func() { b.report(gen, err) }()
+		err = f()
+		return err
+	}}, -1)
+	if !ok {
+		b.abort(gen) // not run: neither a success nor a failure
+	}
+	return ok
+}
+
+// coolDown changes open to half-open after CoolDown. This should be called within a lock.
+func (b *Breaker) coolDown() {
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cfg.CoolDown {
+		b.set(BreakerHalfOpen)
+	}
+}
+
+// set changes the state. This should be called within a lock.
+func (b *Breaker) set(state BreakerState) {
+	b.state = state
+	b.gen += 1
+	b.results, b.failures, b.inRow = 0, 0, 0
+	b.buckets = [breakerBuckets]breakerBucket{}
+	b.inflight, b.probed = 0, 0
+	if state == BreakerOpen {
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// acquire returns the generation if a job can run.
+func (b *Breaker) acquire() (gen uint64, ok bool) {
+	b.mu.Lock()
+	from := b.state
+	b.coolDown()
+	switch b.state {
+	case BreakerClosed:
+		ok = true
+	case BreakerHalfOpen:
+		if b.inflight < b.cfg.Probes-b.probed {
+			b.inflight += 1
+			ok = true
+		}
+	}
+	gen, to := b.gen, b.state
+	onChange := b.onChange
+	b.mu.Unlock()
+
+	if onChange != nil && from != to {
+		onChange(from, to)
+	}
+	return gen, ok
+}
+
+// report records the result of a job acquired in the generation.
+func (b *Breaker) report(gen uint64, err error) {
+	b.mu.Lock()
+	if gen != b.gen { // state has changed since
+		b.mu.Unlock()
+		return
+	}
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		if err != nil {
+			b.inRow += 1
+		} else {
+			b.inRow = 0
+		}
+		results, failures := b.count(err != nil)
+		if (b.cfg.ConsecutiveFailures > 0 && b.inRow >= b.cfg.ConsecutiveFailures) ||
+			(b.cfg.FailureRatio > 0 && results >= b.cfg.MinResults &&
+				float64(failures) >= b.cfg.FailureRatio*float64(results)) {
+			b.set(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		b.inflight -= 1
+		if err != nil {
+			b.set(BreakerOpen)
+		} else if b.probed += 1; b.probed >= b.cfg.Probes {
+			b.set(BreakerClosed)
+		}
+	}
+	to := b.state
+	onChange := b.onChange
+	b.mu.Unlock()
+
+	if onChange != nil && from != to {
+		onChange(from, to)
+	}
+}
+
+// count adds a result, and returns number of results and failures for FailureRatio.
+// This should be called within a lock.
+func (b *Breaker) count(failed bool) (results, failures int) {
+	if b.cfg.Interval <= 0 {
+		b.results += 1
+		if failed {
+			b.failures += 1
+		}
+		return b.results, b.failures
+	}
+
+	width := int64(b.cfg.Interval) / breakerBuckets
+	if width < 1 {
+		width = 1
+	}
+	epoch := b.clock.Now().UnixNano() / width
+	cur := &b.buckets[uint64(epoch)%breakerBuckets]
+	if cur.epoch != epoch { // reuse an expired bucket
+		*cur = breakerBucket{epoch: epoch}
+	}
+	cur.results += 1
+	if failed {
+		cur.failures += 1
+	}
+	for i := range b.buckets {
+		if epoch-b.buckets[i].epoch < breakerBuckets {
+			results += b.buckets[i].results
+			failures += b.buckets[i].failures
+		}
+	}
+	return results, failures
+}
+
+// abort releases a probe acquired but not run.
+func (b *Breaker) abort(gen uint64) {
+	b.mu.Lock()
+	if gen == b.gen && b.state == BreakerHalfOpen {
+		b.inflight -= 1
+	}
+	b.mu.Unlock()
+}
@@ -0,0 +1,124 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestBreaker(t *testing.T) {
+	e := gosl.NewError("down")
+
+	t.Run("Consecutive", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBreaker(limiter.BreakerConfig{ConsecutiveFailures: 3, CoolDown: 10 * time.Second, Probes: 2}, c)
+		changes := make(gosl.Buf, 0, 128)
+		b.OnStateChange(func(from, to limiter.BreakerState) {
+			changes = changes.WriteString(from.String()).WriteString(">").WriteString(to.String()).WriteString(" ")
+		})
+		result := func(err error) {
+			done, ok := b.Allow()
+			gosl.Test(t, true, ok)
+			done(err)
+		}
+
+		result(e)
+		result(e)
+		result(nil) // resets failures in a row
+		result(e)
+		result(e)
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed)
+		stale, _ := b.Allow()
+		result(e)
+		gosl.Test(t, true, b.State() == limiter.BreakerOpen)
+		stale(nil) // acquired before it opened: ignored
+		_, ok := b.Allow()
+		gosl.Test(t, false, ok)
+
+		c.Add(10 * time.Second)
+		p1, ok1 := b.Allow()
+		p2, ok2 := b.Allow()
+		_, ok3 := b.Allow() // only 2 probes
+		gosl.Test(t, true, ok1 && ok2 && !ok3)
+		gosl.Test(t, true, b.State() == limiter.BreakerHalfOpen)
+		p1(nil)
+		gosl.Test(t, true, b.State() == limiter.BreakerHalfOpen)
+		p2(nil)
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed)
+
+		// a failed probe opens it again
+		for i := 0; i < 3; i++ {
+			result(e)
+		}
+		c.Add(10 * time.Second)
+		result(e)
+		gosl.Test(t, true, b.State() == limiter.BreakerOpen)
+		gosl.Test(t, "closed>open open>half-open half-open>closed closed>open open>half-open half-open>open ", changes.String())
+	})
+
+	t.Run("Ratio", func(t *testing.T) {
+		b := limiter.NewBreaker(limiter.BreakerConfig{FailureRatio: 0.5, MinResults: 4}, newFakeClock())
+		for _, err := range []error{nil, e, e} {
+			done, _ := b.Allow()
+			done(err)
+		}
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed) // 2/3, but less than MinResults
+		done, _ := b.Allow()
+		done(nil)
+		gosl.Test(t, true, b.State() == limiter.BreakerOpen) // 2/4
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBreaker(limiter.BreakerConfig{FailureRatio: 0.5, MinResults: 4, Interval: 10 * time.Second}, c)
+		result := func(errs ...error) {
+			for _, err := range errs {
+				done, ok := b.Allow()
+				gosl.Test(t, true, ok)
+				done(err)
+			}
+		}
+		result(nil, nil, nil, nil, nil, nil)
+		c.Add(10 * time.Second) // successes above have expired
+		result(e, e, nil)
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed) // 2/3, but less than MinResults
+		c.Add(5 * time.Second)
+		result(e)
+		gosl.Test(t, true, b.State() == limiter.BreakerOpen) // 3/4
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBreaker(limiter.BreakerConfig{ConsecutiveFailures: 2}, c)
+		l := limiter.NewLimiter(1, 5)
+		rejects := make(chan error, 5)
+		l.OnReject(func(err error) { rejects <- err })
+
+		gosl.Test(t, true, b.Run(l, func() error { return e }))
+		gosl.Test(t, true, b.Run(l, func() error { panic("oops") }))
+		l.Wait()
+		gosl.Test(t, false, b.Run(l, func() error { return nil }))
+		gosl.Test(t, limiter.ErrBreakerOpen, <-rejects)
+		gosl.Test(t, 1, l.Stats().Rejected)
+		gosl.Test(t, 2, l.Stats().Completed)
+		gosl.Test(t, 1, l.Panics())
+		_, failed := l.Results() // not recorded as RunErr()
+		gosl.Test(t, 0, failed)
+		gosl.Test(t, nil, l.Err())
+
+		c.Add(5 * time.Second) // default CoolDown
+		gosl.Test(t, true, b.Run(l, func() error { return nil }))
+		l.Wait()
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed)
+
+		l.Stop(true) // not run: the probe is not counted
+		b = limiter.NewBreaker(limiter.BreakerConfig{}, c)
+		gosl.Test(t, false, b.Run(l, func() error { return nil }))
+		gosl.Test(t, true, b.State() == limiter.BreakerClosed)
+		gosl.Test(t, true, l.Close())
+	})
+}
@@ -0,0 +1,262 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// keyed.go
+// Keyed adds per-key limits on top of a Limiter: at most N jobs run at the same time
+// overall (by the Limiter), and at most perKey jobs for the same key (by Keyed).
+// Jobs for the same key start in the order they were added.
+//
+// When a key is busy, the job waits in the key's own queue instead of the Limiter's.
+// When a job of the key finishes, the next waiting job is added to the Limiter's queue,
+// so it is run like any other job (stats, hooks, and priority aging apply).
+// Once the Limiter stops taking jobs, jobs waiting for a key are cancelled.
+// A key is removed as soon as it has no running or waiting job.
+//
+// Eg. at most 10 concurrent jobs, but 1 per customer, and up to 20 waiting per customer:
+//     k := limiter.NewKeyed(limiter.NewLimiter(10, 100), 1, 20)
+//     k.Run(customerID, func() { ... })
+
+// NewKeyed returns a Keyed using l. At most perKey jobs of a key run at the same time,
+// and at most queue jobs of a key wait for them. If perKey or queue is less than 1,
+// 1 will be used.
+func NewKeyed(l *Limiter, perKey, queue int) *Keyed {
+	if perKey < 1 {
+		perKey = 1
+	}
+	if queue < 1 {
+		queue = 1
+	}
+	return &Keyed{
+		l:      l,
+		mu:     gosl.NewMutex(),
+		perKey: perKey,
+		size:   queue,
+		keys:   make(map[string]*keyState),
+	}
+}
+
+// Keyed is a per-key concurrency limiter using a Limiter's workers.
+type Keyed struct {
+	l      *Limiter
+	mu     gosl.Mutex
+	perKey int // max number of jobs of a key in the Limiter
+	size   int // max number of jobs waiting for a key
+	keys   map[string]*keyState
+}
+
+// keyState is jobs of a key
+type keyState struct {
+	running int           // number of jobs of the key in the Limiter
+	queue   []func()      // jobs waiting for the key
+	space   chan struct{} // when not nil, closed when the queue may have a room
+}
+
+// Run adds a job for the key. If the key already has perKey jobs running, the job
+// waits in the key's queue without taking the Limiter's. When the key's queue is full,
+// this will wait until it has a room. It returns false if the Limiter is not taking jobs.
+func (k *Keyed) Run(key string, f func()) (ok bool) {
+	return k.add(key, f, true)
+}
+
+// TryRun is same as Run(), but it returns false immediately when the key's queue is full,
+// or when the key is free but the Limiter's queue is full.
+func (k *Keyed) TryRun(key string, f func()) (ok bool) {
+	return k.add(key, f, false)
+}
+
+// add adds f for the key. If wait is true, it waits for a room in the key's queue.
+func (k *Keyed) add(key string, f func(), wait bool) (ok bool) {
+	if f == nil {
+		return false
+	}
+
+	for {
+		k.mu.Lock()
+		stop := k.l.stopped()
+		if stop == nil { // closed
+			k.mu.Unlock()
+			return false
+		}
+		select {
+		case <-stop:
+			k.mu.Unlock()
+			k.l.reject(ErrStopped)
+			return false
+		default:
+		}
+
+		ks, ok := k.keys[key]
+		if !ok {
+			ks = &keyState{}
+			k.keys[key] = ks
+		}
+		if ks.running < k.perKey && len(ks.queue) == 0 {
+			ks.running += 1
+			k.mu.Unlock()
+			d := time.Duration(-1)
+			if !wait {
+				d = 0
+			}
+			if k.l.submit(gosl.LvInfo, k.job(key, ks, f), d) {
+				return true
+			}
+			// not added: pass the key to a job queued behind this meanwhile, if any.
+			k.done(key, ks)
+			return false
+		}
+		if len(ks.queue) < k.size { // to keep the order, wait behind others
+			ks.queue = append(ks.queue, f)
+			k.mu.Unlock()
+			return true
+		}
+		if !wait {
+			k.mu.Unlock()
+			k.l.reject(ErrQueueFull)
+			return false
+		}
+		if ks.space == nil {
+			ks.space = make(chan struct{})
+		}
+		space := ks.space
+		k.mu.Unlock()
+
+		select {
+		case <-space:
+		case <-stop:
+		}
+	}
+}
+
+// job wraps f to pass the key to the next waiting job when f is done.
+// If the job is cancelled in the Limiter's queue, jobs waiting for the key are cancelled too.
+func (k *Keyed) job(key string, ks *keyState, f func()) job {
+	return job{
+		f: func() {
+			defer k.done(key, ks) // a panic won't leave the key busy
+			f()
+		},
+		drop: func(error) {
+			k.mu.Lock()
+			ks.running -= 1
+			k.cancel(key, ks, 0)
+			k.mu.Unlock()
+		},
+	}
+}
+
+// done adds the next job waiting for the key to the Limiter, or removes the key
+// if it has nothing to do.
+func (k *Keyed) done(key string, ks *keyState) {
+	k.mu.Lock()
+	if len(ks.queue) == 0 {
+		ks.running -= 1
+		k.release(key, ks)
+		k.mu.Unlock()
+		return
+	}
+	f := ks.queue[0]
+	ks.queue[0] = nil
+	ks.queue = ks.queue[1:]
+	k.signal(ks)
+	k.mu.Unlock()
+
+	if k.l.handoff(gosl.LvInfo, k.job(key, ks, f)) {
+		return
+	}
+	k.mu.Lock()
+	ks.running -= 1
+	k.cancel(key, ks, 1) // including f
+	k.mu.Unlock()
+}
+
+// cancel drops jobs waiting for the key, and counts them and n more as cancelled.
+// This should be called within a lock.
+func (k *Keyed) cancel(key string, ks *keyState, n int) {
+	n += len(ks.queue)
+	for i := range ks.queue {
+		ks.queue[i] = nil
+	}
+	ks.queue = ks.queue[:0]
+	k.signal(ks)
+	k.release(key, ks)
+	if n > 0 {
+		k.l.addCancelled(n)
+	}
+}
+
+// signal wakes up Run() waiting for a room. This should be called within a lock.
+func (k *Keyed) signal(ks *keyState) {
+	if ks.space != nil {
+		close(ks.space)
+		ks.space = nil
+	}
+}
+
+// release removes the key if it has nothing to do. This should be called within a lock.
+func (k *Keyed) release(key string, ks *keyState) {
+	if ks.running == 0 && len(ks.queue) == 0 {
+		delete(k.keys, key)
+	}
+}
+
+// Waiting returns number of jobs waiting for the key.
+func (k *Keyed) Waiting(key string) (n int) {
+	k.mu.Lock()
+	if ks, ok := k.keys[key]; ok {
+		n = len(ks.queue)
+	}
+	k.mu.Unlock()
+	return n
+}
+
+// Len returns number of keys that have a running or waiting job.
+func (k *Keyed) Len() (n int) {
+	k.mu.Lock()
+	n = len(k.keys)
+	k.mu.Unlock()
+	return n
+}
+
+// stopped returns a channel closed when the limiter stops taking jobs.
+// If the limiter is closed, it returns nil.
+func (l *Limiter) stopped() (stop <-chan struct{}) {
+	if l.mu == nil {
+		return nil
+	}
+	l.mu <- struct{}{} // lock
+	stop = l.stop
+	<-l.mu // unlock
+	return stop
+}
+
+// handoff adds j from a running job. It doesn't wait for a room: the worker is about
+// to take the next job, so the queue can go over its size for a moment. If all workers
+// waited for a room here, nothing would take a job from the queue.
+// Unlike submit(), it doesn't count a rejection when the limiter is not taking jobs.
+func (l *Limiter) handoff(level gosl.LvLevel, j job) (ok bool) {
+	j.at = time.Now()
+	l.mu <- struct{}{} // lock
+	if !l.status {
+		<-l.mu // unlock
+		return false
+	}
+	l.pending += 1
+	l.stats.Submitted += 1
+	l.queue.push(level, j)
+	<-l.mu // unlock
+	return true
+}
+
+// addCancelled counts n jobs cancelled outside of the queue.
+func (l *Limiter) addCancelled(n int) {
+	l.mu <- struct{}{} // lock
+	l.stats.Cancelled += n
+	<-l.mu // unlock
+}
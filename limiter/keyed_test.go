@@ -0,0 +1,176 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestKeyed(t *testing.T) {
+	for _, perKey := range []int{1, 2} {
+		l := limiter.NewLimiter(4, 100)
+		k := limiter.NewKeyed(l, perKey, 20)
+
+		keys := []string{"a", "b", "c"}
+		var total, maxTotal int32
+		running := make([]int32, len(keys))
+		maxRunning := make([]int32, len(keys))
+		order := make([]chan int, len(keys))
+		for i := range order {
+			order[i] = make(chan int, 20)
+		}
+		max := func(p *int32, v int32) {
+			for {
+				old := atomic.LoadInt32(p)
+				if v <= old || atomic.CompareAndSwapInt32(p, old, v) {
+					return
+				}
+			}
+		}
+
+		for n := 0; n < 20; n++ {
+			for i := range keys {
+				i, n := i, n
+				gosl.Test(t, true, k.Run(keys[i], func() {
+					max(&maxTotal, atomic.AddInt32(&total, 1))
+					max(&maxRunning[i], atomic.AddInt32(&running[i], 1))
+					order[i] <- n
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&running[i], -1)
+					atomic.AddInt32(&total, -1)
+				}))
+			}
+		}
+		l.Wait()
+
+		gosl.Test(t, true, atomic.LoadInt32(&maxTotal) <= 4)
+		for i := range keys {
+			gosl.Test(t, true, int(atomic.LoadInt32(&maxRunning[i])) <= perKey)
+			sum := 0
+			for n := 0; n < 20; n++ {
+				v := <-order[i]
+				if perKey == 1 {
+					gosl.Test(t, n, v) // run in order
+				}
+				sum += v
+			}
+			gosl.Test(t, 190, sum)
+		}
+		gosl.Test(t, 0, k.Len())              // idle keys are removed
+		gosl.Test(t, 60, l.Stats().Completed) // waiting jobs run through the Limiter
+		gosl.Test(t, true, l.Close())
+	}
+}
+
+func TestKeyed_Panic(t *testing.T) {
+	l := limiter.NewLimiter(2, 10)
+	k := limiter.NewKeyed(l, 1, 5)
+	gate := make(chan struct{})
+	done := false
+	k.Run("a", func() {
+		<-gate
+		panic("oops")
+	})
+	k.Run("a", func() { done = true })
+	gosl.Test(t, 1, k.Waiting("a"))
+	gosl.Test(t, 1, k.Len())
+	close(gate)
+	l.Wait()
+	gosl.Test(t, true, done)
+	gosl.Test(t, 1, l.Panics())
+	gosl.Test(t, 0, k.Len())
+
+	l.Stop(true)
+	gosl.Test(t, false, k.Run("a", func() {}))
+	gosl.Test(t, 0, k.Len())
+	gosl.Test(t, true, l.Close())
+}
+
+func TestKeyed_Queue(t *testing.T) {
+	l := limiter.NewLimiter(2, 10)
+	k := limiter.NewKeyed(l, 1, 1)
+	gate := make(chan struct{})
+	var n int32
+	gosl.Test(t, true, k.TryRun("a", func() { <-gate }))
+	gosl.Test(t, true, k.TryRun("a", func() { atomic.AddInt32(&n, 1) }))
+	gosl.Test(t, false, k.TryRun("a", func() {})) // the key's queue is full
+	gosl.Test(t, true, k.TryRun("b", func() {}))  // other keys are not affected
+	gosl.Test(t, 1, l.Stats().Rejected)
+
+	added := make(chan bool)
+	go func() { added <- k.Run("a", func() { atomic.AddInt32(&n, 1) }) }()
+	select {
+	case <-added:
+		t.Fatal("Run() should wait for a room")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(gate)
+	gosl.Test(t, true, <-added)
+	l.Wait()
+	gosl.Test(t, 2, int(atomic.LoadInt32(&n)))
+	gosl.Test(t, 0, k.Len())
+	gosl.Test(t, true, l.Close())
+}
+
+func TestKeyed_Stop(t *testing.T) {
+	l := limiter.NewLimiter(2, 10)
+	k := limiter.NewKeyed(l, 1, 5)
+	gate := make(chan struct{})
+	var n int32
+	k.Run("a", func() { <-gate })
+	k.Run("a", func() { atomic.AddInt32(&n, 1) })
+	k.Run("a", func() { atomic.AddInt32(&n, 1) })
+	l.Stop(false)
+	close(gate)
+	l.Wait()
+	gosl.Test(t, 0, int(atomic.LoadInt32(&n))) // waiting jobs are cancelled
+	gosl.Test(t, 2, l.Stats().Cancelled)
+	gosl.Test(t, 0, k.Len())
+	gosl.Test(t, false, k.Run("a", func() {}))
+	gosl.Test(t, true, l.Close())
+}
+
+func TestKeyed_StopQueued(t *testing.T) {
+	l := limiter.NewLimiter(1, 10)
+	k := limiter.NewKeyed(l, 1, 5)
+	gate := make(chan struct{})
+	var n int32
+	l.Run(func() { <-gate })                      // the only worker is busy
+	k.Run("a", func() { atomic.AddInt32(&n, 1) }) // in the Limiter's queue
+	k.Run("a", func() { atomic.AddInt32(&n, 1) }) // waiting for the key
+	gosl.Test(t, 1, k.Waiting("a"))
+	l.Stop(false)
+	close(gate)
+	l.Wait()
+	gosl.Test(t, 0, int(atomic.LoadInt32(&n)))
+	gosl.Test(t, 2, l.Stats().Cancelled)
+	gosl.Test(t, 0, k.Waiting("a"))
+	gosl.Test(t, 0, k.Len())
+	gosl.Test(t, true, l.Close())
+}
+
+func TestKeyed_TryRun(t *testing.T) {
+	l := limiter.NewLimiter(1, 1)
+	k := limiter.NewKeyed(l, 1, 5)
+	gate := make(chan struct{})
+	l.Run(func() { <-gate }) // the only worker is busy
+	l.Run(func() {})         // the Limiter's queue is full
+
+	added := make(chan bool, 1)
+	go func() { added <- k.TryRun("a", func() {}) }()
+	select {
+	case ok := <-added:
+		gosl.Test(t, false, ok)
+	case <-time.After(time.Second):
+		t.Fatal("TryRun() should not wait for the Limiter's queue")
+	}
+	gosl.Test(t, 0, k.Len())
+	close(gate)
+	l.Wait()
+	gosl.Test(t, true, l.Close())
+}
@@ -12,13 +12,43 @@ package limiter
 //             someCode() // Code that needs to run concurrently
 //         })
 //     }
-//     l.Stop(false) // Stop the limiter. Anything after this will be skipped.
-//     for l.IsActive() == false { // Wait until it's completely stopped
-//         time.Sleep(time.Second))
-//     }
-//     l.Close() // Close the Limiter
+//     l.Stop(true) // Stop taking new jobs, but let jobs in the queue to be finished.
+//     l.Wait()     // Wait until every accepted job has completed
+//     l.Close()    // Close the Limiter
+//
+// Priority:
+//     l.RunPriority(gosl.LvError, healthCheck) // dispatched before jobs added by Run() (LvInfo)
+//
+// Resizing:
+//     l.Resize(20, 0) // 20 workers, keep the queue size. Shrinking takes effect as running jobs complete.
+//
+// Cancellation:
+//     l.RunDeadline(func(t *limiter.Token) { // job gets a Token
+//         for moreWork() {
+//             select {
+//             case <-t.Done(): // closed by StopAndWait() or when the deadline has passed
+//                 return
+//             default:
+//                 doWork()
+//             }
+//         }
+//     }, time.Now().Add(time.Minute))
+//     l.StopAndWait(10 * time.Second) // signals running jobs, and waits until they finish
 //
 
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+var (
+	ErrCancelled = gosl.NewError("limiter: cancelled")
+	ErrDeadline  = gosl.NewError("limiter: deadline exceeded")
+	ErrStopped   = gosl.NewError("limiter: not taking jobs")
+	ErrQueueFull = gosl.NewError("limiter: queue is full")
+)
+
 // NewLimiter will return a *Limiter
 func NewLimiter(worker, queue uint16) *Limiter {
 	l := &Limiter{}
@@ -33,130 +63,497 @@ func NewLimiter(worker, queue uint16) *Limiter {
 }
 
 // Limiter is a queue based concurrent runner.
+// Workers are goroutines started when a job arrives, and each of them keeps taking jobs
+// from the queue until the queue is empty. Capacities can be changed by Resize().
 type Limiter struct {
-	worker chan struct{} // worker limits how many concurrent
-	queue  chan func()   // queue for jobs
-	mu     chan struct{} // mutex
-	status bool          // only when status is true, new job can be added to queue.
+	workers int           // max number of concurrent workers
+	active  int           // number of workers running
+	size    int           // max number of jobs in the queue
+	queue   jobQueue      // queue for jobs
+	space   chan struct{} // when not nil, closed when the queue may have a room
+	mu      chan struct{} // mutex
+	status  bool          // only when status is true, new job can be added to queue.
+	stop    chan struct{} // closed when status becomes false to wake waiting retries
+	rnd     uint64        // xorshift state for retry jitter
+
+	cancel    chan struct{} // closed by StopAndWait() to signal running jobs
+	cancelled bool          // true when cancel is closed
+	pending   int           // number of jobs accepted, and not finished yet
+	skipped   int           // number of jobs skipped by workers after StopAndWait()
+	idle      chan struct{} // when not nil, closed when pending becomes 0
+	res       results       // results of jobs added by RunErr()
+	stats     Stats         // cumulative counters since Init()
+	hooks     hooks         // callbacks, kept after Init()
 }
 
 // Init initialize Limiter
 // If queue size, job may hold until enough queue is available.
 func (l *Limiter) Init(workers, queue uint16) (lim *Limiter, ok bool) {
-	// do not allow unbuffered channel.
+	// do not allow zero capacity.
 	if workers == 0 || queue == 0 {
 		return nil, false
 	}
 
 	// l.mu will be nil if the Limiter (1) is a fresh object, or (2) has called Closed()
 	if l.mu != nil {
-		return l, false // when Closed() is not called, *Limiter.monitor() maybe still running.
+		return l, false // when Closed() is not called, workers may be still running.
 	}
 
-	l.worker = make(chan struct{}, workers)
-	l.queue = make(chan func(), queue)
+	l.workers = int(workers)
+	l.active = 0
+	l.size = int(queue)
+	l.queue = jobQueue{aging: DefaultAging}
+	l.space = nil
 	l.mu = make(chan struct{}, 1) // mutex, let only 1 at a time
 	l.status = true               // false -> true
-	go l.monitor()                // start monitoring in background. this will be cancelled only when Close() is called.
+	l.stop = make(chan struct{})
+	l.rnd = uint64(time.Now().UnixNano()) | 1 // xorshift state can't be 0
+	l.cancel = make(chan struct{})
+	l.cancelled = false
+	l.skipped = 0
+	l.pending = 0
+	l.idle = nil
+	l.res = results{}
+	l.stats = Stats{}
 	return l, true
 }
 
-// ifStatusIs will compare status with *Limiter.status. If same, this will return true.
-// Also, if status and new values are differ, it will switch status value to new value.
-func (l *Limiter) ifStatusIs(status, new bool) (match bool) {
-	l.mu <- struct{}{}         // lock
-	match = l.status == status // match (output) will be true if a func argument status is same as l.status.
-	if match && status != new {
-		l.status = new // when given status and new are different, update status AFTER calculate the match (above)
+// work runs j, and keeps taking jobs from the queue until the queue is empty,
+// or there are more workers than the limiter allows.
+func (l *Limiter) work(j job) {
+	for ok := true; ok; j, ok = l.next() {
+		l.exec(j)
+	}
+}
+
+// exec runs a job, and records its stats. A panic won't stop the worker.
+func (l *Limiter) exec(j job) {
+	start := time.Now()
+	if !j.until.IsZero() && !start.Before(j.until) { // RunDeadline(): too late to start
+		l.mu <- struct{}{} // lock
+		l.stats.Cancelled += 1
+		<-l.mu // unlock
+		if j.drop != nil {
+			j.drop(ErrDeadline)
+		}
+		return
+	}
+	wait := start.Sub(j.at)
+	l.mu <- struct{}{} // lock
+	l.stats.Started += 1
+	l.stats.QueueWait.add(wait)
+	onStart := l.hooks.onStart
+	<-l.mu // unlock
+	if onStart != nil {
+		onStart(wait)
+	}
+
+	var err error
+	if j.fe != nil { // added by RunErr(), RunRetry(), or Breaker.Run()
+		if perr := l.protect(func() { err = j.fe() }); perr != nil {
+			err = perr // panic will be counted as a failure
+		}
+		if j.id > 0 && (err == nil || j.retry == nil || !l.retry(j, err)) {
+			l.result(j.id, err)
+		}
+	} else {
+		err = l.protect(j.f)
 	}
+
+	took := time.Since(start)
+	l.mu <- struct{}{} // lock
+	l.stats.Completed += 1
+	l.stats.RunTime.add(took)
+	onFinish := l.hooks.onFinish
 	<-l.mu // unlock
-	return
+	if onFinish != nil {
+		onFinish(took, err)
+	}
 }
 
-// monitor monitors queue, and when new queue arrives, it will hand it to worker if available.
-// otherwise, it will wait a worker become available.
-// monitor will stop when *Limiter.queue is closed.
-func (l *Limiter) monitor() {
-loop:
-	for {
-		select {
-		case f, ok := <-l.queue: // as soon as one gets out, new one can be there. and until ifStatusIs, one can be there as well.
-			if ok {
-				l.worker <- struct{}{} // WAIT until worker is available ** THIS CAN HIDE 1 JOB THAT GOES TO WORKER
-				go func() {
-					f()
-					<-l.worker // NO ISSUE even when empty and closed EXCEPT WHEN cap(worker)=0
-				}()
-			} else { // if queue is closed, it will exit the monitor
-				break loop
-			}
+// next counts the last job as finished, and returns the next job to run.
+// If ok is false, the worker should exit.
+func (l *Limiter) next() (j job, ok bool) {
+	l.mu <- struct{}{} // lock
+	l.done(1)
+	skipped := 0
+	var drops []func(error)
+	for l.active <= l.workers && l.queue.len() > 0 {
+		j = l.pop()
+		if !l.cancelled {
+			<-l.mu // unlock
+			return j, true
 		}
+		skipped += 1 // StopAndWait() was called while the job was in the queue
+		if j.drop != nil {
+			drops = append(drops, j.drop)
+		}
+	}
+	l.skipped += skipped
+	l.stats.Cancelled += skipped
+	l.active -= 1 // queue is empty, or shrunk by Resize()
+	<-l.mu        // unlock
+	l.dropped(skipped, drops)
+	return job{}, false
+}
+
+// dropped calls drop funcs of n jobs cancelled in the queue, and then counts the jobs as
+// finished, so Wait() returns after the drop funcs. This should be called outside a lock.
+func (l *Limiter) dropped(n int, drops []func(error)) {
+	for _, drop := range drops {
+		drop(ErrCancelled)
+	}
+	if n > 0 {
+		l.mu <- struct{}{} // lock
+		l.done(n)
+		<-l.mu // unlock
+	}
+}
+
+// pop takes the next job from the queue. This should be called within a lock.
+func (l *Limiter) pop() (j job) {
+	j = l.queue.pop()
+	l.signal()
+	return j
+}
+
+// signal wakes up Run() waiting for a room. This should be called within a lock.
+func (l *Limiter) signal() {
+	if l.space != nil {
+		close(l.space)
+		l.space = nil
 	}
 }
 
-// Run adds a job func() to queue. If limiter is no longer accepting, it will return false.
+// halt stops taking new jobs, and wakes up Run() and retries waiting.
+// This should be called within a lock.
+func (l *Limiter) halt() {
+	l.status = false
+	l.signal()
+	close(l.stop)
+}
+
+// done counts n jobs as finished, and wakes up waitIdle() when nothing is pending.
+// This should be called within a lock.
+func (l *Limiter) done(n int) {
+	l.pending -= n
+	if l.pending == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// Run adds a job func() to queue with gosl.LvInfo priority. If limiter is no longer accepting,
+// it will return false. When the queue is full, this will wait until the queue has a room.
 func (l *Limiter) Run(f func()) (ok bool) {
+	return l.submit(gosl.LvInfo, job{f: f}, -1)
+}
+
+// TryRun adds a job to the queue only if the queue has a room. If the queue is full or the
+// limiter is not taking jobs, it will return false immediately instead of blocking.
+func (l *Limiter) TryRun(f func()) (ok bool) {
+	return l.submit(gosl.LvInfo, job{f: f}, 0)
+}
+
+// RunWithin adds a job to the queue, but gives up when the queue is still full after d.
+// If d is 0 or less, it is same as TryRun().
+func (l *Limiter) RunWithin(f func(), d time.Duration) (ok bool) {
+	if d < 0 {
+		d = 0
+	}
+	return l.submit(gosl.LvInfo, job{f: f}, d)
+}
+
+// submit hands j to a new worker if available, otherwise adds it to the queue.
+// When the queue is full, it waits for d. If d is less than 0, it waits without a timeout.
+// A job not taken is counted as rejected.
+func (l *Limiter) submit(level gosl.LvLevel, j job, d time.Duration) (ok bool) {
+	ok, err := l.add(level, j, d)
+	if err != nil {
+		l.reject(err)
+	}
+	return ok
+}
+
+// add is submit without counting a rejection. err is why j was not taken.
+func (l *Limiter) add(level gosl.LvLevel, j job, d time.Duration) (ok bool, err error) {
 	// don't let nil to get in as a func
-	if f == nil {
+	if (j.f == nil && j.fe == nil) || l.mu == nil {
+		return false, nil
+	}
+	j.at = time.Now()
+
+	var timeout <-chan time.Time
+	for {
+		l.mu <- struct{}{} // lock
+		if !l.status {
+			<-l.mu // unlock
+			return false, ErrStopped
+		}
+		if l.active < l.workers {
+			l.pending += 1
+			l.active += 1
+			l.stats.Submitted += 1
+			<-l.mu // unlock
+			go l.work(j)
+			return true, nil
+		}
+		if l.queue.len() < l.size {
+			l.pending += 1
+			l.stats.Submitted += 1
+			l.queue.push(level, j)
+			<-l.mu // unlock
+			return true, nil
+		}
+		if d == 0 {
+			<-l.mu // unlock
+			return false, ErrQueueFull
+		}
+		if l.space == nil {
+			l.space = make(chan struct{})
+		}
+		space := l.space
+		<-l.mu // unlock
+
+		if d > 0 && timeout == nil {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-space:
+		case <-timeout:
+			return false, ErrQueueFull
+		}
+	}
+}
+
+// Resize changes number of workers and queue size. If the value is 0 or less, it will not
+// be changed. When workers are increased, queued jobs start right away. When decreased,
+// it takes effect as running jobs complete. Queued jobs are never dropped; when the queue
+// is shrunk below the number of queued jobs, new jobs will wait until it has a room.
+func (l *Limiter) Resize(workers, queue int) (ok bool) {
+	if l.mu == nil {
 		return false
 	}
+	l.mu <- struct{}{} // lock
+	if workers > 0 {
+		l.workers = workers
+	}
+	if queue > 0 {
+		l.size = queue
+	}
+	for l.active < l.workers && l.queue.len() > 0 {
+		l.active += 1
+		go l.work(l.pop())
+	}
+	l.signal()
+	<-l.mu // unlock
+	return true
+}
 
-	// Add new jobs to the queue if status says its available
-	if l.ifStatusIs(true, true) {
-		l.queue <- f
-		return true
+// Capacity returns number of workers and queue size currently set.
+func (l *Limiter) Capacity() (workers, queue int) {
+	if l.mu == nil {
+		return 0, 0
 	}
+	l.mu <- struct{}{} // lock
+	workers, queue = l.workers, l.size
+	<-l.mu // unlock
+	return workers, queue
+}
 
-	return ok
+// RunToken adds a job that takes a Token. Token.Done() will be closed when StopAndWait() is called.
+func (l *Limiter) RunToken(f func(t *Token)) (ok bool) {
+	return l.RunDeadline(f, time.Time{})
+}
+
+// RunDeadline adds a job that takes a Token with a deadline. Token.Done() will be closed
+// when the deadline has passed or StopAndWait() is called. If the deadline has passed before
+// the job starts, the job will be skipped and counted as cancelled. Zero deadline means no deadline.
+func (l *Limiter) RunDeadline(f func(t *Token), deadline time.Time) (ok bool) {
+	if f == nil {
+		return false
+	}
+	return l.submit(gosl.LvInfo, job{
+		f:     func() { f(newToken(l.cancel, deadline)) },
+		until: deadline,
+	}, -1)
 }
 
 // Stop will make limiter stop taking jobs.
 // - allow == false: All jobs in the queue will be cancelled and return how many were cancelled.
 // - allow == true:  this will let all jobs in the queue to be finished.
 func (l *Limiter) Stop(allow bool) (cancelled int) {
-	if l.ifStatusIs(true, false) { // change accept status to false, so worker can't take a job
-		if allow { // allow queue to be finished
-			return 0
-		}
-		// drain all jobs in the queue
-		cancelled = 0
-		for len(l.queue) > 0 {
-			<-l.queue
-			cancelled += 1
+	if l.mu == nil {
+		return 0
+	}
+	var drops []func(error)
+	l.mu <- struct{}{} // lock
+	if l.status {      // change accept status to false, so new job can't be added
+		l.halt()
+		if !allow { // drain all jobs in the queue
+			cancelled, drops = l.queue.clear()
+			l.stats.Cancelled += cancelled
 		}
+	}
+	<-l.mu // unlock
+	l.dropped(cancelled, drops)
+	return cancelled // return how many jobs in queue has been cancelled (drained)
+}
 
-		return cancelled // return how many jobs in queue has been cancelled (drained)
+// StopAndWait will stop taking jobs, cancel all jobs in the queue, and signal running jobs
+// through their Token. Then it waits until all running jobs finish up to timeout.
+// If timeout is 0 or less, it will wait without a timeout. ok will be false when timed out.
+func (l *Limiter) StopAndWait(timeout time.Duration) (cancelled int, ok bool) {
+	if l.mu == nil {
+		return 0, true
+	}
+	cancelled = l.Stop(false)
+
+	l.mu <- struct{}{} // lock
+	if !l.cancelled {
+		l.cancelled = true
+		close(l.cancel)
+	}
+	<-l.mu // unlock
+
+	ok = l.waitIdle(timeout)
+
+	l.mu <- struct{}{}     // lock
+	cancelled += l.skipped // jobs that were waiting for a worker
+	l.skipped = 0
+	<-l.mu // unlock
+	return cancelled, ok
+}
+
+// waitIdle waits until no job is pending. When timed out, it returns false.
+func (l *Limiter) waitIdle(timeout time.Duration) bool {
+	l.mu <- struct{}{} // lock
+	if l.pending == 0 {
+		<-l.mu // unlock
+		return true
+	}
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+	}
+	idle := l.idle
+	<-l.mu // unlock
+
+	if timeout <= 0 {
+		<-idle
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
 	}
-	return 0
 }
 
 // Status shows current limiter status. (state: currently taking a job)
+// For cumulative counters, use Stats().
 func (l *Limiter) Status() (state bool, activeWorkers, activeQueue int) {
 	if l.mu != nil {
-		return l.ifStatusIs(true, true), len(l.worker), len(l.queue)
+		l.mu <- struct{}{} // lock
+		state, activeWorkers, activeQueue = l.status, l.active, l.queue.len()
+		<-l.mu // unlock
 	}
-	return false, 0, 0
+	return state, activeWorkers, activeQueue
 }
 
-// IsActive will return true if there's something running.
-// This can be used as a part of wait-all-jobs.
-func (l *Limiter) IsActive() bool {
-	if s, w, q := l.Status(); s == false && w+q == 0 {
+// IsActive will return true if the limiter is taking jobs, or an accepted job hasn't completed yet.
+func (l *Limiter) IsActive() (active bool) {
+	if l.mu == nil {
 		return false
 	}
-	return true
+	l.mu <- struct{}{} // lock
+	active = l.status || l.pending > 0
+	<-l.mu // unlock
+	return active
+}
+
+// Wait blocks until every accepted job has completed. When Wait returns while the limiter
+// is stopped, Close() will succeed.
+func (l *Limiter) Wait() {
+	if l.mu == nil {
+		return
+	}
+	l.waitIdle(0)
 }
 
 // Close will attempt to close the limiter. If a job is currently running, it will return false.
 func (l *Limiter) Close() (ok bool) {
+	if l.mu == nil { // already closed
+		return false
+	}
 	l.Stop(false)     // if already stopped, this will ignore
 	if l.IsActive() { // if the limiter is still active, return false
 		return false
 	}
 
-	close(l.queue)  // this will also close the monitor()
-	close(l.worker) // DO NOT DRAIN THE WORKER: when all jobs are done, its size should be 0 anyway.
 	close(l.mu)
 	l.mu = nil // only after closing all channels, mu will set nil in case Init() is called later.
 	return true
-}
\ No newline at end of file
+}
+
+// Token is given to a job to tell when the job should stop, without importing context.
+type Token struct {
+	done     chan struct{} // closed when cancelled or the deadline has passed
+	cancel   chan struct{} // limiter's cancel
+	deadline time.Time
+}
+
+// newToken creates a Token. The token stays valid after the job returns, as the job
+// may have handed it to other goroutines; with a deadline, a goroutine waits until
+// the deadline or cancel.
+func newToken(cancel chan struct{}, deadline time.Time) (t *Token) {
+	t = &Token{
+		cancel:   cancel,
+		deadline: deadline,
+	}
+	if deadline.IsZero() { // no need for a goroutine
+		t.done = cancel
+		return t
+	}
+
+	t.done = make(chan struct{})
+	timer := time.NewTimer(time.Until(deadline))
+	go func() {
+		select {
+		case <-cancel:
+			timer.Stop()
+		case <-timer.C:
+		}
+		close(t.done)
+	}()
+	return t
+}
+
+// Done returns a channel that will be closed when the job should stop.
+func (t *Token) Done() <-chan struct{} {
+	return t.done
+}
+
+// Deadline returns the deadline of the job. If there's no deadline, ok will be false.
+func (t *Token) Deadline() (deadline time.Time, ok bool) {
+	return t.deadline, !t.deadline.IsZero()
+}
+
+// Err returns nil if the job can continue. Otherwise, ErrCancelled or ErrDeadline.
+func (t *Token) Err() error {
+	select {
+	case <-t.cancel:
+		return ErrCancelled
+	default:
+	}
+	select {
+	case <-t.done:
+		return ErrDeadline
+	default:
+		return nil
+	}
+}
@@ -4,7 +4,9 @@
 package limiter_test
 
 import (
+	"github.com/gonyyi/gosl"
 	"github.com/gonyyi/gosl/limiter"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -35,3 +37,189 @@ func TestLimiter(t *testing.T) {
 	}
 	println("FINISHED")
 }
+
+func TestLimiter_Wait(t *testing.T) {
+	for n := 0; n < 10; n++ {
+		l := limiter.NewLimiter(2, 3)
+		var done int32
+		for i := 0; i < 10; i++ {
+			l.Run(func() {
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&done, 1)
+			})
+		}
+		if n%2 == 0 {
+			l.Stop(true) // let the queue to be finished
+		}
+		l.Wait()
+		gosl.Test(t, 10, int(atomic.LoadInt32(&done)))
+		gosl.Test(t, true, l.Close())
+		gosl.Test(t, false, l.IsActive())
+		l.Wait() // closed limiter won't block
+		gosl.Test(t, false, l.Close())
+	}
+}
+
+func TestLimiter_TryRun(t *testing.T) {
+	l := limiter.NewLimiter(1, 2)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	gosl.Test(t, true, l.TryRun(func() {
+		close(started)
+		<-block
+	}))
+	<-started
+
+	var done int32
+	job := func() { atomic.AddInt32(&done, 1) }
+	n := 0
+	for l.TryRun(job) {
+		n++
+	}
+	gosl.Test(t, 2, n) // queue size
+
+	start := time.Now()
+	gosl.Test(t, false, l.RunWithin(job, 20*time.Millisecond))
+	gosl.Test(t, true, time.Since(start) >= 20*time.Millisecond)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(block)
+	}()
+	gosl.Test(t, true, l.RunWithin(job, time.Second))
+	gosl.Test(t, false, l.TryRun(nil))
+
+	l.Stop(true)
+	gosl.Test(t, false, l.TryRun(job))
+	l.Wait()
+	gosl.Test(t, n+1, int(atomic.LoadInt32(&done)))
+	gosl.Test(t, true, l.Close())
+	gosl.Test(t, false, l.TryRun(job))
+}
+
+func TestLimiter_Resize(t *testing.T) {
+	l := limiter.NewLimiter(1, 10)
+	started := make(chan struct{}, 10)
+	gate := make(chan struct{})
+	var done int32
+	for i := 0; i < 10; i++ {
+		l.Run(func() {
+			started <- struct{}{}
+			<-gate
+			atomic.AddInt32(&done, 1)
+		})
+	}
+	status := func(workers, queue int) bool {
+		for i := 0; i < 100; i++ {
+			if _, w, q := l.Status(); w == workers && q == queue {
+				return true
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return false
+	}
+
+	<-started
+	gosl.Test(t, true, status(1, 9))
+
+	// grow: queued jobs start right away
+	gosl.Test(t, true, l.Resize(4, 0))
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	gosl.Test(t, true, status(4, 6))
+
+	// shrink: running jobs are not affected, and queued jobs are kept
+	l.Resize(2, 2)
+	w, q := l.Capacity()
+	gosl.Test(t, 2, w)
+	gosl.Test(t, 2, q)
+	gosl.Test(t, false, l.TryRun(func() {}))
+	gate <- struct{}{}
+	gate <- struct{}{}
+	gosl.Test(t, true, status(2, 6))
+	gosl.Test(t, 0, len(started))
+
+	for i := 0; i < 8; i++ {
+		gate <- struct{}{}
+	}
+	l.Wait()
+	gosl.Test(t, 10, int(atomic.LoadInt32(&done)))
+	gosl.Test(t, true, l.Close())
+	gosl.Test(t, false, l.Resize(1, 1))
+}
+
+func TestLimiter_StopAndWait(t *testing.T) {
+	l := limiter.NewLimiter(2, 5)
+	started := make(chan struct{}, 2)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		l.RunToken(func(tk *limiter.Token) {
+			started <- struct{}{}
+			<-tk.Done()
+			errs <- tk.Err()
+		})
+	}
+	<-started
+	<-started
+	for i := 0; i < 3; i++ {
+		l.Run(func() { t.Error("should have been cancelled") })
+	}
+
+	cancelled, ok := l.StopAndWait(time.Second)
+	gosl.Test(t, 3, cancelled)
+	gosl.Test(t, true, ok)
+	gosl.Test(t, limiter.ErrCancelled, <-errs)
+	gosl.Test(t, limiter.ErrCancelled, <-errs)
+	gosl.Test(t, false, l.Run(func() {}))
+	gosl.Test(t, true, l.Close())
+}
+
+func TestLimiter_StopAndWaitTimeout(t *testing.T) {
+	l := limiter.NewLimiter(1, 1)
+	started := make(chan struct{})
+	l.Run(func() { // does not check a token
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+	})
+	<-started
+	_, ok := l.StopAndWait(10 * time.Millisecond)
+	gosl.Test(t, false, ok)
+	_, ok = l.StopAndWait(0) // wait without a timeout
+	gosl.Test(t, true, ok)
+	gosl.Test(t, true, l.Close())
+}
+
+func TestLimiter_RunDeadline(t *testing.T) {
+	l := limiter.NewLimiter(1, 2)
+	errs := make(chan error, 1)
+	l.RunDeadline(func(tk *limiter.Token) {
+		_, ok := tk.Deadline()
+		gosl.Test(t, true, ok)
+		gosl.Test(t, nil, tk.Err())
+		<-tk.Done()
+		errs <- tk.Err()
+	}, time.Now().Add(50*time.Millisecond))
+	gosl.Test(t, limiter.ErrDeadline, <-errs)
+
+	// token handed to another goroutine stays valid after the job returns
+	tokens := make(chan *limiter.Token, 1)
+	l.RunDeadline(func(tk *limiter.Token) { tokens <- tk }, time.Now().Add(time.Hour))
+	l.Wait()
+	tk := <-tokens
+	gosl.Test(t, nil, tk.Err())
+	select {
+	case <-tk.Done():
+		t.Error("Done() should not be closed before the deadline")
+	default:
+	}
+
+	// deadline has already passed: skipped, and counted as cancelled
+	l.RunDeadline(func(tk *limiter.Token) { t.Error("should have been skipped") }, time.Now())
+	l.Wait()
+	gosl.Test(t, 1, l.Stats().Cancelled)
+	gosl.Test(t, 2, l.Stats().Completed)
+	l.StopAndWait(time.Second)
+	gosl.Test(t, limiter.ErrCancelled, tk.Err())
+	l.Close()
+}
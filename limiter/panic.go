@@ -0,0 +1,77 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"github.com/gonyyi/gosl"
+)
+
+// panic.go
+// Every job runs with a recover, so a panicking job won't crash the process,
+// and its worker will keep taking jobs. A recovered panic becomes *PanicError.
+// Jobs added by RunErr() will report it as a failure.
+//
+// Eg.
+//     l.OnPanic(func(err error) {
+//         println(err.Error()) // limiter: panic: some value
+//     })
+
+var ErrPanic = gosl.NewError("limiter: panic")
+
+// PanicError is an error converted from a panic in a job.
+// gosl.IsError(err, ErrPanic) will be true for it.
+type PanicError struct {
+	Value interface{} // value given to panic()
+}
+
+// Error to meet the error interface
+func (e *PanicError) Error() string {
+	switch v := e.Value.(type) {
+	case string:
+		return ErrPanic.Error() + ": " + v
+	case error:
+		return ErrPanic.Error() + ": " + v.Error()
+	case int:
+		return ErrPanic.Error() + ": " + gosl.Itoa(v)
+	default:
+		return ErrPanic.Error()
+	}
+}
+
+// Unwrap returns ErrPanic
+func (e *PanicError) Unwrap() error {
+	return ErrPanic
+}
+
+// OnPanic sets a handler that will be called with *PanicError when a job panics.
+// The handler runs in the worker of the job. Set nil to remove it.
+func (l *Limiter) OnPanic(f func(err error)) {
+	l.setHook(func(h *hooks) { h.onPanic = f })
+}
+
+// Panics returns number of jobs panicked since Init().
+func (l *Limiter) Panics() (count int) {
+	if l.mu == nil {
+		return 0
+	}
+	l.mu <- struct{}{} // lock
+	count = l.stats.Panicked
+	<-l.mu // unlock
+	return count
+}
+
+// protect runs f, and converts a panic to *PanicError.
+func (l *Limiter) protect(f func()) (err error) {
+	defer gosl.IfPanic(func(r interface{}) {
+		err = &PanicError{Value: r}
+		l.mu <- struct{}{} // lock
+		l.stats.Panicked += 1
+		h := l.hooks.onPanic
+		<-l.mu // unlock
+		if h != nil {
+			h(err)
+		}
+	})
+	f()
+	return nil
+}
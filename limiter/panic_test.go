@@ -0,0 +1,40 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestLimiter_Panic(t *testing.T) {
+	e := gosl.NewError("broken")
+	l := limiter.NewLimiter(1, 5) // with 1 worker, a leaked worker would block everything
+	handled := make(chan error, 5)
+	l.OnPanic(func(err error) { handled <- err })
+
+	l.Run(func() { panic("plain") })
+	l.RunErr(func() error { panic(e) })
+	l.RunToken(func(tk *limiter.Token) { panic(1) })
+	l.Run(func() { panic(struct{}{}) })
+	done := false
+	l.Run(func() { done = true })
+	l.Wait()
+
+	gosl.Test(t, true, done)
+	gosl.Test(t, 4, l.Panics())
+	gosl.Test(t, 4, len(handled))
+	for _, v := range []string{"limiter: panic: plain", "limiter: panic: broken", "limiter: panic: 1", "limiter: panic"} {
+		err := <-handled
+		gosl.Test(t, v, err.Error())
+		gosl.Test(t, true, gosl.IsError(err, limiter.ErrPanic))
+	}
+
+	_, failed := l.Results()
+	gosl.Test(t, 1, failed)
+	gosl.Test(t, "limiter: job 1: limiter: panic: broken", l.Err().Error())
+	gosl.Test(t, true, gosl.IsError(l.Err(), limiter.ErrPanic))
+	gosl.Test(t, true, l.Close())
+}
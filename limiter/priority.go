@@ -0,0 +1,120 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// priority.go
+// Each job has a priority level using gosl.LvLevel (LvTrace ... LvFatal), and a job with
+// a higher level will be dispatched first. Run() uses LvInfo. Jobs with the same level
+// are dispatched in order (FIFO).
+//
+// To prevent starvation, a waiting job gains one level for every DefaultAging jobs
+// dispatched while it waits (aging). This can be changed by SetAging().
+//
+// Eg.
+//     l.RunPriority(gosl.LvError, healthCheck) // dispatched before LvInfo and below
+//     l.RunPriority(gosl.LvTrace, batchJob)    // dispatched after others, but not forever
+
+// DefaultAging is number of dispatched jobs for a waiting job to gain one level.
+const DefaultAging = 8
+
+// RunPriority adds a job with a priority level. Level higher than gosl.LvFatal will be
+// treated as gosl.LvFatal. Like Run(), it will wait when the queue is full.
+func (l *Limiter) RunPriority(level gosl.LvLevel, f func()) (ok bool) {
+	return l.submit(level, job{f: f}, -1)
+}
+
+// SetAging sets number of dispatched jobs for a waiting job to gain one level.
+// If n is 0 or less, aging is disabled and a lower level job will wait as long as
+// there's a higher level job. Init() will set it back to DefaultAging.
+func (l *Limiter) SetAging(n int) {
+	if l.mu == nil {
+		return
+	}
+	l.mu <- struct{}{} // lock
+	l.queue.aging = n
+	<-l.mu // unlock
+}
+
+// job is a queued job
+type job struct {
+	f     func()
+	fe    func() error // instead of f, for RunErr()
+	id    int          // job number for RunErr(), 0 to not record the result
+	retry *retryState  // for RunRetry()
+	at    time.Time    // when the job was added
+	until time.Time    // for RunDeadline(), skipped if this has passed before the job starts
+	seq   int          // jobQueue.seq when the job was added
+	drop  func(error)  // when not nil, called instead of running when the job is cancelled
+}
+
+// jobQueue is a queue for each level. This is protected by Limiter.mu.
+type jobQueue struct {
+	levels [gosl.LvFatal + 1][]job
+	n      int // number of jobs in all levels
+	seq    int // number of jobs dispatched
+	aging  int
+}
+
+// len returns number of jobs in the queue
+func (q *jobQueue) len() int {
+	return q.n
+}
+
+// push adds j to the level
+func (q *jobQueue) push(level gosl.LvLevel, j job) {
+	if level > gosl.LvFatal {
+		level = gosl.LvFatal
+	}
+	j.seq = q.seq
+	q.levels[level] = append(q.levels[level], j)
+	q.n += 1
+}
+
+// pop returns the first job of the level with the highest priority after aging.
+// When priorities are same, a higher level will be chosen.
+func (q *jobQueue) pop() (j job) {
+	best, bestPri := -1, -1
+	for lv := len(q.levels) - 1; lv >= 0; lv-- {
+		if len(q.levels[lv]) == 0 {
+			continue
+		}
+		pri := lv
+		if q.aging > 0 {
+			pri += (q.seq - q.levels[lv][0].seq) / q.aging
+		}
+		if pri > bestPri {
+			best, bestPri = lv, pri
+		}
+	}
+	if best < 0 {
+		return job{}
+	}
+
+	j = q.levels[best][0]
+	q.levels[best][0] = job{}
+	q.levels[best] = q.levels[best][1:]
+	q.n -= 1
+	q.seq += 1
+	return j
+}
+
+// clear removes all jobs, and returns number of jobs removed and their drop funcs
+func (q *jobQueue) clear() (n int, drops []func(error)) {
+	for lv := range q.levels {
+		for i := range q.levels[lv] {
+			if q.levels[lv][i].drop != nil {
+				drops = append(drops, q.levels[lv][i].drop)
+			}
+			q.levels[lv][i] = job{}
+		}
+		q.levels[lv] = q.levels[lv][:0]
+	}
+	n, q.n = q.n, 0
+	return n, drops
+}
@@ -0,0 +1,89 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestLimiter_RunPriority(t *testing.T) {
+	// block the only worker, so jobs stay in the queue until the gate opens
+	blocked := func(l *limiter.Limiter) (open func()) {
+		gate := make(chan struct{})
+		started := make(chan struct{})
+		l.Run(func() {
+			close(started)
+			<-gate
+		})
+		<-started
+		return func() { close(gate) }
+	}
+	collect := func(order chan string) string {
+		buf := make(gosl.Buf, 0, 128)
+		for len(order) > 0 {
+			buf = buf.WriteString(<-order)
+		}
+		return buf.String()
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 10)
+		l.SetAging(0)
+		order := make(chan string, 10)
+		open := blocked(l)
+		for _, v := range []struct {
+			lv   gosl.LvLevel
+			name string
+		}{
+			{gosl.LvInfo, "i"}, {gosl.LvTrace, "t"}, {gosl.LvFatal, "f"},
+			{gosl.LvInfo, "I"}, {gosl.LvError, "e"}, {255, "F"},
+		} {
+			name := v.name
+			gosl.Test(t, true, l.RunPriority(v.lv, func() { order <- name }))
+		}
+		l.Run(func() { order <- "r" }) // LvInfo
+		open()
+		l.Wait()
+		gosl.Test(t, "fFeiIrt", collect(order))
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Aging", func(t *testing.T) {
+		for _, aging := range []int{0, 2} {
+			l := limiter.NewLimiter(1, 10)
+			l.SetAging(aging)
+			order := make(chan string, 64)
+			open := blocked(l)
+
+			// while high priority jobs keep coming, a low priority job waits
+			n := 0
+			var stream func()
+			stream = func() {
+				order <- "f"
+				if n++; n < 30 {
+					l.RunPriority(gosl.LvFatal, stream)
+				}
+			}
+			l.RunPriority(gosl.LvTrace, func() { order <- "t" })
+			l.RunPriority(gosl.LvFatal, stream)
+			open()
+			l.Wait()
+
+			out := collect(order)
+			gosl.Test(t, 31, len(out))
+			pos := 0
+			for out[pos] != 't' {
+				pos++
+			}
+			if aging == 0 {
+				gosl.Test(t, 30, pos) // starved until the stream ends
+			} else {
+				gosl.Test(t, true, pos > 1 && pos < 20)
+			}
+			gosl.Test(t, true, l.Close())
+		}
+	})
+}
@@ -0,0 +1,256 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// rate.go
+// Limiter limits concurrency, Bucket and Window limit rate.
+// - Bucket: a token bucket; tokens are refilled at a rate up to burst.
+// - Window: a sliding window counter per key (eg. user ID, IP address).
+// Both take a Clock, so tests can control the time without sleeping.
+//
+// Eg.
+//     b := limiter.NewBucket(10, 20, nil) // 10 per second, burst of 20, system clock
+//     if !b.Allow() {
+//         return 429
+//     }
+//     w := limiter.NewWindow(100, time.Minute, nil) // 100 per minute for each key
+//     if !w.Allow(userIP) {
+//         return 429
+//     }
+
+// Clock provides the current time, and sleeps for Bucket.Wait().
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is a Clock using time package. It is used when nil Clock is given.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// *************************************************************************
+// Token Bucket
+// *************************************************************************
+
+// NewBucket returns a token bucket that refills rate tokens per second up to burst.
+// The bucket starts full. If rate is 0 or less, tokens won't be refilled.
+func NewBucket(rate float64, burst int, clock Clock) *Bucket {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		mu:     gosl.NewMutex(),
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// Bucket is a token bucket rate limiter.
+type Bucket struct {
+	mu     gosl.Mutex
+	clock  Clock
+	rate   float64 // tokens per second
+	burst  float64 // max tokens
+	tokens float64 // can be negative when reserved
+	last   time.Time
+}
+
+// refill adds tokens for the time elapsed since last refill. This should be called within a lock.
+func (b *Bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// Allow takes a token if available. It does not wait.
+func (b *Bucket) Allow() (ok bool) {
+	b.mu.Lock()
+	b.refill(b.clock.Now())
+	if b.tokens >= 1 {
+		b.tokens -= 1
+		ok = true
+	}
+	b.mu.Unlock()
+	return ok
+}
+
+// Reserve takes n tokens now, and returns how long to wait before acting on them.
+// Tokens are reserved even if they are not available yet, so later callers wait longer.
+// If n is greater than burst, or tokens can never be refilled, ok will be false
+// and nothing will be reserved.
+func (b *Bucket) Reserve(n int) (wait time.Duration, ok bool) {
+	if n < 1 {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.clock.Now())
+	need := float64(n)
+	if need > b.burst || (need > b.tokens && b.rate <= 0) {
+		return 0, false
+	}
+	b.tokens -= need
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	return wait, true
+}
+
+// Wait takes a token, and sleeps until the token is available.
+// If a token can never be available, it returns false without waiting.
+func (b *Bucket) Wait() (ok bool) {
+	wait, ok := b.Reserve(1)
+	if ok && wait > 0 {
+		b.clock.Sleep(wait)
+	}
+	return ok
+}
+
+// Tokens returns number of tokens currently available. It can be negative when reserved.
+func (b *Bucket) Tokens() (n float64) {
+	b.mu.Lock()
+	b.refill(b.clock.Now())
+	n = b.tokens
+	b.mu.Unlock()
+	return n
+}
+
+// *************************************************************************
+// Sliding Window
+// *************************************************************************
+
+// NewWindow returns a sliding window counter that allows limit events per size for each key.
+func NewWindow(limit int, size time.Duration, clock Clock) *Window {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if size <= 0 {
+		size = time.Second
+	}
+	return &Window{
+		mu:    gosl.NewMutex(),
+		clock: clock,
+		limit: limit,
+		size:  size,
+		keys:  make(map[string]*window),
+	}
+}
+
+// Window is a sliding window counter keyed by string. Instead of keeping every event,
+// it keeps counts of the current and previous fixed windows, and estimates the count of
+// the sliding window by weighting the previous count by how much of it overlaps.
+type Window struct {
+	mu    gosl.Mutex
+	clock Clock
+	limit int
+	size  time.Duration
+	keys  map[string]*window
+}
+
+// window is counts of a key
+type window struct {
+	start time.Time // start of the current fixed window
+	prev  int       // count of the previous fixed window
+	curr  int       // count of the current fixed window
+}
+
+// get returns counts of the key moved to the window of now. This should be called within a lock.
+func (w *Window) get(key string, now time.Time) *window {
+	c, ok := w.keys[key]
+	if !ok {
+		c = &window{start: now.Truncate(w.size)}
+		w.keys[key] = c
+		return c
+	}
+	if elapsed := now.Sub(c.start); elapsed >= w.size {
+		if elapsed < 2*w.size { // the current becomes the previous
+			c.prev = c.curr
+		} else {
+			c.prev = 0
+		}
+		c.curr = 0
+		c.start = now.Truncate(w.size)
+	}
+	return c
+}
+
+// estimate returns the estimated count of the sliding window ending at now.
+func (w *Window) estimate(c *window, now time.Time) float64 {
+	weight := 1 - float64(now.Sub(c.start))/float64(w.size)
+	return float64(c.prev)*weight + float64(c.curr)
+}
+
+// Allow counts an event for the key if the key is under the limit.
+func (w *Window) Allow(key string) (ok bool) {
+	w.mu.Lock()
+	now := w.clock.Now()
+	c := w.get(key, now)
+	if w.estimate(c, now) < float64(w.limit) {
+		c.curr += 1
+		ok = true
+	}
+	w.mu.Unlock()
+	return ok
+}
+
+// Remaining returns how many more events the key can have now.
+func (w *Window) Remaining(key string) (n int) {
+	w.mu.Lock()
+	now := w.clock.Now()
+	n = w.limit
+	if _, ok := w.keys[key]; ok {
+		r := float64(w.limit) - w.estimate(w.get(key, now), now)
+		if n = int(r); float64(n) < r { // round up: Allow() is ok while estimate < limit
+			n += 1
+		}
+	}
+	w.mu.Unlock()
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Cleanup removes keys that were not used for the last two windows, and returns
+// number of keys removed. Call this periodically when keys are not a fixed set.
+func (w *Window) Cleanup() (removed int) {
+	w.mu.Lock()
+	now := w.clock.Now()
+	for k, c := range w.keys {
+		if now.Sub(c.start) >= 2*w.size {
+			delete(w.keys, k)
+			removed += 1
+		}
+	}
+	w.mu.Unlock()
+	return removed
+}
+
+// Len returns number of keys tracked.
+func (w *Window) Len() (n int) {
+	w.mu.Lock()
+	n = len(w.keys)
+	w.mu.Unlock()
+	return n
+}
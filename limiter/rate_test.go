@@ -0,0 +1,115 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+// fakeClock only moves when Sleep or Add is called
+type fakeClock struct {
+	mu  gosl.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{mu: gosl.NewMutex(), now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) { c.Add(d) }
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestBucket(t *testing.T) {
+	t.Run("Allow", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBucket(10, 3, c) // 10 per second, burst of 3
+		for i := 0; i < 3; i++ {
+			gosl.Test(t, true, b.Allow())
+		}
+		gosl.Test(t, false, b.Allow())
+		c.Add(50 * time.Millisecond)
+		gosl.Test(t, false, b.Allow()) // 0.5 token
+		c.Add(50 * time.Millisecond)
+		gosl.Test(t, true, b.Allow())
+		c.Add(time.Hour)
+		gosl.Test(t, true, b.Tokens() == 3) // up to burst
+	})
+
+	t.Run("Reserve", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBucket(10, 5, c)
+		wait, ok := b.Reserve(5)
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, wait == 0)
+		wait, ok = b.Reserve(2)
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, wait == 200*time.Millisecond)
+		wait, _ = b.Reserve(1) // waits behind the previous reservation
+		gosl.Test(t, true, wait == 300*time.Millisecond)
+		_, ok = b.Reserve(6) // more than burst
+		gosl.Test(t, false, ok)
+		gosl.Test(t, false, b.Allow())
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		c := newFakeClock()
+		b := limiter.NewBucket(2, 1, c)
+		start := c.Now()
+		for i := 0; i < 5; i++ {
+			gosl.Test(t, true, b.Wait())
+		}
+		gosl.Test(t, true, c.Now().Sub(start) == 2*time.Second) // first one from the burst
+
+		b = limiter.NewBucket(0, 1, c) // never refills
+		gosl.Test(t, true, b.Wait())
+		gosl.Test(t, false, b.Wait())
+	})
+}
+
+func TestWindow(t *testing.T) {
+	c := newFakeClock()
+	w := limiter.NewWindow(4, time.Minute, c)
+
+	for i := 0; i < 4; i++ {
+		gosl.Test(t, true, w.Allow("a"))
+	}
+	gosl.Test(t, false, w.Allow("a"))
+	gosl.Test(t, 0, w.Remaining("a"))
+	gosl.Test(t, true, w.Allow("b")) // each key has its own count
+	gosl.Test(t, 3, w.Remaining("b"))
+	gosl.Test(t, 4, w.Remaining("c"))
+
+	// 15s into the next window, 75% of the previous window still counts: 4*0.75 = 3
+	c.Add(time.Minute + 15*time.Second)
+	gosl.Test(t, 1, w.Remaining("a"))
+	gosl.Test(t, true, w.Allow("a"))
+	gosl.Test(t, false, w.Allow("a"))
+
+	// 45s into the window: 4*0.25 + 1 = 2
+	c.Add(30 * time.Second)
+	gosl.Test(t, 2, w.Remaining("a"))
+
+	gosl.Test(t, 2, w.Len())
+	gosl.Test(t, 0, w.Cleanup())
+	c.Add(30 * time.Second) // "b" was not used for 2 windows
+	gosl.Test(t, 1, w.Cleanup())
+	c.Add(time.Minute)
+	gosl.Test(t, 1, w.Cleanup())
+	gosl.Test(t, 0, w.Len())
+	gosl.Test(t, true, w.Allow("a"))
+}
@@ -0,0 +1,119 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"github.com/gonyyi/gosl"
+)
+
+// result.go
+// Jobs added by RunErr() report an error. The limiter keeps the first error,
+// all errors, and counts of succeeded and failed jobs until Init() is called again.
+//
+// Eg. errgroup-like usage:
+//     l.FailFast(true) // stop taking new jobs after the first failure
+//     for _, v := range items {
+//         v := v
+//         if !l.RunErr(func() error { return process(v) }) {
+//             break
+//         }
+//     }
+//     l.Wait()
+//     if err := l.Err(); err != nil { ... }
+
+// results holds results of jobs added by RunErr(). Protected by Limiter.mu.
+type results struct {
+	jobs      int     // number of jobs added by RunErr(), used to name errors
+	succeeded int     // number of jobs returned nil
+	failed    int     // number of jobs returned an error
+	first     error   // first error
+	errs      []error // all errors, wrapped with the job number
+	failFast  bool    // when true, stop taking new jobs after the first failure
+}
+
+// RunErr adds a job that returns an error. Returned errors can be checked by Err() and Errors().
+func (l *Limiter) RunErr(f func() error) (ok bool) {
+	if f == nil || l.mu == nil {
+		return false
+	}
+	l.mu <- struct{}{} // lock
+	if !l.status {
+		<-l.mu // unlock
+		l.reject(ErrStopped)
+		return false
+	}
+	l.res.jobs += 1
+	id := l.res.jobs
+	<-l.mu // unlock
+
+	return l.submit(gosl.LvInfo, job{fe: f, id: id}, -1)
+}
+
+// FailFast will make the limiter stop taking new jobs after the first job added
+// by RunErr() has failed. Jobs already accepted will still run.
+func (l *Limiter) FailFast(enable bool) {
+	if l.mu == nil {
+		return
+	}
+	l.mu <- struct{}{} // lock
+	l.res.failFast = enable
+	<-l.mu // unlock
+}
+
+// Err returns the first error returned by a job. If there's none, nil.
+func (l *Limiter) Err() (err error) {
+	if l.mu == nil {
+		return nil
+	}
+	l.mu <- struct{}{} // lock
+	err = l.res.first
+	<-l.mu // unlock
+	return err
+}
+
+// Errors returns all errors returned by jobs in the order they were reported.
+// Each error is wrapped with its job number (eg. "limiter: job 3: some error"),
+// so gosl.IsError() can still find the original error.
+func (l *Limiter) Errors() (errs []error) {
+	if l.mu == nil {
+		return nil
+	}
+	l.mu <- struct{}{} // lock
+	if len(l.res.errs) > 0 {
+		errs = make([]error, len(l.res.errs))
+		copy(errs, l.res.errs)
+	}
+	<-l.mu // unlock
+	return errs
+}
+
+// Results returns number of jobs added by RunErr() that have succeeded and failed.
+func (l *Limiter) Results() (succeeded, failed int) {
+	if l.mu == nil {
+		return 0, 0
+	}
+	l.mu <- struct{}{} // lock
+	succeeded, failed = l.res.succeeded, l.res.failed
+	<-l.mu // unlock
+	return succeeded, failed
+}
+
+// result records an error returned by the job id.
+func (l *Limiter) result(id int, err error) {
+	l.mu <- struct{}{} // lock
+	if err == nil {
+		l.res.succeeded += 1
+		<-l.mu // unlock
+		return
+	}
+	l.res.failed += 1
+	err = gosl.WrapError("limiter: job "+gosl.Itoa(id), err)
+	if l.res.first == nil {
+		l.res.first = err
+	}
+	l.res.errs = append(l.res.errs, err)
+	if l.res.failFast && l.status {
+		l.halt() // stop taking new jobs
+	}
+	<-l.mu // unlock
+}
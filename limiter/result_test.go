@@ -0,0 +1,71 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestLimiter_RunErr(t *testing.T) {
+	e := gosl.NewError("odd")
+
+	t.Run("Collect", func(t *testing.T) {
+		l := limiter.NewLimiter(3, 3)
+		for i := 1; i <= 10; i++ {
+			i := i
+			gosl.Test(t, true, l.RunErr(func() error {
+				if i%2 == 1 {
+					return e
+				}
+				return nil
+			}))
+		}
+		gosl.Test(t, false, l.RunErr(nil))
+		l.Wait()
+
+		s, f := l.Results()
+		gosl.Test(t, 5, s)
+		gosl.Test(t, 5, f)
+		gosl.Test(t, true, gosl.IsError(l.Err(), e))
+
+		errs := l.Errors()
+		gosl.Test(t, 5, len(errs))
+		seen := make(map[string]bool)
+		for _, err := range errs {
+			gosl.Test(t, true, gosl.IsError(err, e))
+			seen[err.Error()] = true
+		}
+		for _, v := range []string{"limiter: job 1: odd", "limiter: job 5: odd", "limiter: job 9: odd"} {
+			gosl.Test(t, true, seen[v])
+		}
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 1)
+		l.FailFast(true)
+		failed := make(chan struct{})
+		l.RunErr(func() error {
+			defer close(failed)
+			return e
+		})
+		<-failed
+		l.Wait()
+		gosl.Test(t, false, l.RunErr(func() error { return nil }))
+		gosl.Test(t, false, l.Run(func() {}))
+		gosl.Test(t, "limiter: job 1: odd", l.Err().Error())
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 1)
+		l.RunErr(func() error { return nil })
+		l.Wait()
+		gosl.Test(t, nil, l.Err())
+		gosl.Test(t, 0, len(l.Errors()))
+		gosl.Test(t, true, l.Close())
+	})
+}
@@ -0,0 +1,156 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+
+	"github.com/gonyyi/gosl"
+)
+
+// retry.go
+// RunRetry runs a job until it succeeds or the policy gives up. While waiting for the
+// next attempt, the job does not take a worker; after the delay, it is added to the
+// queue again like a new job. Like RunErr(), only the final result is recorded.
+// If the limiter stops taking jobs, a waiting retry gives up with the last error right
+// away, and it is counted as cancelled.
+//
+// Eg.
+//     l.RunRetry(callAPI, limiter.RetryPolicy{
+//         Backoff:     limiter.BackoffJitter,
+//         Delay:       100 * time.Millisecond,
+//         MaxDelay:    5 * time.Second,
+//         MaxAttempts: 5,
+//         MaxElapsed:  time.Minute,
+//     })
+
+// DefaultMaxAttempts is used when neither MaxAttempts nor MaxElapsed is set.
+const DefaultMaxAttempts = 3
+
+// Backoff decides how the delay grows between attempts.
+type Backoff uint8
+
+const (
+	BackoffFixed       Backoff = iota // Delay for every retry
+	BackoffExponential                // Delay, Delay*2, Delay*4, ... up to MaxDelay
+	BackoffJitter                     // random between 0 and the exponential delay
+)
+
+// RetryPolicy is how RunRetry() retries a failed job.
+type RetryPolicy struct {
+	Backoff     Backoff
+	Delay       time.Duration // delay before the first retry
+	MaxDelay    time.Duration // max delay between attempts, 0 for no limit
+	MaxAttempts int           // max attempts including the first one, 0 for no limit
+	MaxElapsed  time.Duration // no more retry after this since the job was added, 0 for no limit
+}
+
+// delay returns the delay before the attempt n (2 for the first retry).
+// rnd is a random number used by BackoffJitter.
+func (p *RetryPolicy) delay(n int, rnd uint64) (d time.Duration) {
+	d = p.Delay
+	if p.Backoff != BackoffFixed {
+		for i := 2; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+			if d > d<<1 { // overflow
+				break
+			}
+			d <<= 1
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Backoff == BackoffJitter && d > 0 {
+		d = time.Duration(rnd % (uint64(d) + 1))
+	}
+	return d
+}
+
+// retryState is kept across attempts of a job
+type retryState struct {
+	policy  RetryPolicy
+	attempt int       // number of attempts made
+	start   time.Time // when the job was added
+}
+
+// RunRetry adds a job that will be retried by the policy when it returns an error.
+// The final error can be checked by Err() and Errors().
+func (l *Limiter) RunRetry(f func() error, policy RetryPolicy) (ok bool) {
+	if f == nil || l.mu == nil {
+		return false
+	}
+	if policy.MaxAttempts <= 0 && policy.MaxElapsed <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+
+	l.mu <- struct{}{} // lock
+	if !l.status {
+		<-l.mu // unlock
+		l.reject(ErrStopped)
+		return false
+	}
+	l.res.jobs += 1
+	id := l.res.jobs
+	<-l.mu // unlock
+
+	return l.submit(gosl.LvInfo, job{
+		fe:    f,
+		id:    id,
+		retry: &retryState{policy: policy, start: time.Now()},
+	}, -1)
+}
+
+// retry schedules the next attempt of j if the policy allows.
+// If it returns false, the job won't be retried.
+func (l *Limiter) retry(j job, err error) (ok bool) {
+	r := j.retry
+	r.attempt += 1
+	p := &r.policy
+	if p.MaxAttempts > 0 && r.attempt >= p.MaxAttempts {
+		return false
+	}
+
+	l.mu <- struct{}{} // lock
+	if !l.status {
+		<-l.mu // unlock
+		return false
+	}
+	delay := p.delay(r.attempt+1, l.random())
+	if p.MaxElapsed > 0 && time.Since(r.start)+delay > p.MaxElapsed {
+		<-l.mu // unlock
+		return false
+	}
+	l.pending += 1 // keep Wait() waiting until the next attempt is added
+	stop := l.stop
+	<-l.mu // unlock
+
+	go func() {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-stop: // Stop(), StopAndWait()
+			timer.Stop()
+		}
+		ok, _ := l.add(gosl.LvInfo, j, -1)
+		if !ok {
+			l.result(j.id, err) // gave up with the last error
+		}
+		l.mu <- struct{}{} // lock
+		if !ok {
+			l.stats.Cancelled += 1
+		}
+		l.done(1)
+		<-l.mu // unlock
+	}()
+	return true
+}
+
+// random returns the next xorshift64 number. This should be called within a lock.
+func (l *Limiter) random() uint64 {
+	x := l.rnd
+	x ^= x << 13
+	x ^= x >> 7
+	x ^= x << 17
+	l.rnd = x
+	return x
+}
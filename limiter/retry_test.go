@@ -0,0 +1,126 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestLimiter_RunRetry(t *testing.T) {
+	e := gosl.NewError("flaky")
+	failUntil := func(n int32, attempts *int32) func() error {
+		return func() error {
+			if atomic.AddInt32(attempts, 1) < n {
+				return e
+			}
+			return nil
+		}
+	}
+
+	t.Run("Succeed", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 5)
+		var attempts int32
+		gosl.Test(t, true, l.RunRetry(failUntil(3, &attempts), limiter.RetryPolicy{Delay: time.Millisecond}))
+		l.Wait()
+		gosl.Test(t, 3, int(attempts))
+		s, f := l.Results()
+		gosl.Test(t, 1, s)
+		gosl.Test(t, 0, f)
+		gosl.Test(t, 3, l.Stats().Started)
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 5)
+		var attempts int32
+		start := time.Now()
+		l.RunRetry(failUntil(10, &attempts), limiter.RetryPolicy{
+			Backoff:     limiter.BackoffExponential,
+			Delay:       10 * time.Millisecond,
+			MaxAttempts: 4,
+		})
+		l.Wait()
+		gosl.Test(t, 4, int(attempts))
+		gosl.Test(t, true, time.Since(start) >= 70*time.Millisecond) // 10 + 20 + 40
+		gosl.Test(t, "limiter: job 1: flaky", l.Err().Error())
+		_, f := l.Results()
+		gosl.Test(t, 1, f)
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("MaxElapsed", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 5)
+		var attempts int32
+		l.RunRetry(failUntil(10, &attempts), limiter.RetryPolicy{
+			Backoff:    limiter.BackoffJitter,
+			Delay:      30 * time.Millisecond,
+			MaxDelay:   40 * time.Millisecond,
+			MaxElapsed: 50 * time.Millisecond,
+		})
+		l.Wait()
+		gosl.Test(t, true, attempts >= 2 && attempts < 10)
+		gosl.Test(t, true, gosl.IsError(l.Err(), e))
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("FreeWorker", func(t *testing.T) {
+		// while a job waits for the next attempt, another job can use the only worker
+		l := limiter.NewLimiter(1, 5)
+		order := make(chan string, 5)
+		var attempts int32
+		l.RunRetry(func() error {
+			order <- "r"
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				l.Run(func() { order <- "x" })
+				return e
+			}
+			return nil
+		}, limiter.RetryPolicy{Delay: 30 * time.Millisecond})
+		l.Wait()
+		gosl.Test(t, "rxr", <-order+<-order+<-order)
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 5)
+		var rejected int32
+		l.OnReject(func(error) { atomic.AddInt32(&rejected, 1) })
+		started := make(chan struct{}, 1)
+		l.RunRetry(func() error {
+			started <- struct{}{}
+			return e
+		}, limiter.RetryPolicy{Delay: time.Hour, MaxAttempts: 2})
+		<-started
+		time.Sleep(10 * time.Millisecond)
+		start := time.Now()
+		l.Stop(false)
+		l.Wait() // the waiting retry gives up without waiting for an hour
+		gosl.Test(t, true, time.Since(start) < time.Second)
+		gosl.Test(t, "limiter: job 1: flaky", l.Err().Error())
+		gosl.Test(t, 0, l.Stats().Rejected)
+		gosl.Test(t, 1, l.Stats().Cancelled)
+		gosl.Test(t, 0, int(atomic.LoadInt32(&rejected)))
+		gosl.Test(t, true, l.Close())
+	})
+
+	t.Run("StopAndWait", func(t *testing.T) {
+		l := limiter.NewLimiter(1, 5)
+		started := make(chan struct{}, 1)
+		l.RunRetry(func() error {
+			started <- struct{}{}
+			return e
+		}, limiter.RetryPolicy{Delay: time.Hour, MaxAttempts: 2})
+		<-started
+		time.Sleep(10 * time.Millisecond)
+		_, ok := l.StopAndWait(time.Second) // does not wait for an hour
+		gosl.Test(t, true, ok)
+		gosl.Test(t, true, gosl.IsError(l.Err(), e))
+		gosl.Test(t, 1, l.Stats().Started)
+		gosl.Test(t, true, l.Close())
+	})
+}
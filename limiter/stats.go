@@ -0,0 +1,135 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter
+
+import (
+	"time"
+)
+
+// stats.go
+// Stats has cumulative counters since Init(), and histograms of how long jobs
+// waited in the queue and how long they ran. Hooks are called outside the lock,
+// so they can be used to export metrics or write logs.
+//
+// Eg. with gosl.LvWriter:
+//     l.OnReject(func(err error) {
+//         lw.Warn().WriteString(err.Error())
+//     })
+//     l.OnFinish(func(took time.Duration, err error) {
+//         if err != nil {
+//             lw.Error().WriteString(err.Error())
+//         }
+//     })
+
+// HistogramBounds are upper bounds of Histogram buckets.
+var HistogramBounds = [...]time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// Histogram counts durations by HistogramBounds.
+// Buckets[i] is number of durations less than or equal to HistogramBounds[i],
+// and greater than the previous bound. The last bucket is for longer than a minute.
+type Histogram struct {
+	Buckets [len(HistogramBounds) + 1]int
+	Count   int
+	Sum     time.Duration
+	Max     time.Duration
+}
+
+// add counts a duration
+func (h *Histogram) add(d time.Duration) {
+	i := 0
+	for i < len(HistogramBounds) && d > HistogramBounds[i] {
+		i++
+	}
+	h.Buckets[i] += 1
+	h.Count += 1
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean returns the average duration
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Stats is a snapshot of the limiter's counters.
+type Stats struct {
+	Submitted int // jobs accepted
+	Started   int // jobs started running
+	Completed int // jobs finished running, including panicked
+	Cancelled int // jobs dropped before running by a stop, or by a deadline passed in the queue
+	Rejected  int // jobs not accepted because the limiter was stopped, or the queue was full
+	Panicked  int // jobs panicked
+
+	QueueWait Histogram // time from accepted to started
+	RunTime   Histogram // time from started to finished
+}
+
+// hooks are callbacks set by On* methods
+type hooks struct {
+	onStart  func(wait time.Duration)
+	onFinish func(took time.Duration, err error)
+	onReject func(err error)
+	onPanic  func(err error)
+}
+
+// Stats returns a snapshot of counters since Init().
+func (l *Limiter) Stats() (s Stats) {
+	if l.mu == nil {
+		return s
+	}
+	l.mu <- struct{}{} // lock
+	s = l.stats
+	<-l.mu // unlock
+	return s
+}
+
+// OnStart sets a hook called in the worker right before a job starts with how long it waited.
+func (l *Limiter) OnStart(f func(wait time.Duration)) {
+	l.setHook(func(h *hooks) { h.onStart = f })
+}
+
+// OnFinish sets a hook called in the worker after a job finished with how long it ran.
+// err is the error returned by a job added by RunErr(), or *PanicError if it panicked.
+func (l *Limiter) OnFinish(f func(took time.Duration, err error)) {
+	l.setHook(func(h *hooks) { h.onFinish = f })
+}
+
+// OnReject sets a hook called when a job is not accepted, with ErrStopped or ErrQueueFull.
+func (l *Limiter) OnReject(f func(err error)) {
+	l.setHook(func(h *hooks) { h.onReject = f })
+}
+
+// setHook updates hooks. Hooks can be set before Init().
+func (l *Limiter) setHook(f func(h *hooks)) {
+	if l.mu == nil {
+		f(&l.hooks)
+		return
+	}
+	l.mu <- struct{}{} // lock
+	f(&l.hooks)
+	<-l.mu // unlock
+}
+
+// reject counts a rejected job, and calls the hook.
+func (l *Limiter) reject(err error) {
+	l.mu <- struct{}{} // lock
+	l.stats.Rejected += 1
+	onReject := l.hooks.onReject
+	<-l.mu // unlock
+	if onReject != nil {
+		onReject(err)
+	}
+}
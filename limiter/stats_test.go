@@ -0,0 +1,77 @@
+// (c) Gon Y. Yi 2022 <https://gonyyi.com/copyright>
+
+package limiter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonyyi/gosl"
+	"github.com/gonyyi/gosl/limiter"
+)
+
+func TestLimiter_Stats(t *testing.T) {
+	e := gosl.NewError("failed")
+	l := limiter.NewLimiter(1, 2)
+
+	starts := make(chan time.Duration, 10)
+	finishes := make(chan error, 10)
+	rejects := make(chan error, 10)
+	l.OnStart(func(wait time.Duration) { starts <- wait })
+	l.OnFinish(func(took time.Duration, err error) { finishes <- err })
+	l.OnReject(func(err error) { rejects <- err })
+
+	block := func() (open func()) {
+		gate := make(chan struct{})
+		started := make(chan struct{})
+		l.Run(func() {
+			close(started)
+			<-gate
+		})
+		<-started
+		return func() { close(gate) }
+	}
+
+	open := block()
+	l.TryRun(func() { panic("oops") })
+	l.RunErr(func() error { return e })
+	gosl.Test(t, false, l.TryRun(func() {})) // queue is full
+	time.Sleep(10 * time.Millisecond)
+	open()
+	l.Wait()
+
+	open = block()
+	l.TryRun(func() {})
+	l.TryRun(func() {})
+	gosl.Test(t, 2, l.Stop(false))
+	open()
+	l.Wait()
+	gosl.Test(t, false, l.Run(func() {})) // stopped
+
+	s := l.Stats()
+	gosl.Test(t, 6, s.Submitted)
+	gosl.Test(t, 4, s.Started)
+	gosl.Test(t, 4, s.Completed)
+	gosl.Test(t, 2, s.Cancelled)
+	gosl.Test(t, 2, s.Rejected)
+	gosl.Test(t, 1, s.Panicked)
+	gosl.Test(t, 4, s.RunTime.Count)
+	gosl.Test(t, 4, s.QueueWait.Count)
+	gosl.Test(t, true, s.RunTime.Max >= 10*time.Millisecond)   // first job held by the gate
+	gosl.Test(t, true, s.QueueWait.Max >= 10*time.Millisecond) // jobs waited behind it
+	gosl.Test(t, true, s.RunTime.Mean() > 0 && s.RunTime.Mean() <= s.RunTime.Max)
+	sum := 0
+	for _, n := range s.RunTime.Buckets {
+		sum += n
+	}
+	gosl.Test(t, 4, sum)
+
+	gosl.Test(t, 4, len(starts))
+	gosl.Test(t, nil, <-finishes)
+	gosl.Test(t, true, gosl.IsError(<-finishes, limiter.ErrPanic))
+	gosl.Test(t, e, <-finishes)
+	gosl.Test(t, nil, <-finishes)
+	gosl.Test(t, limiter.ErrQueueFull, <-rejects)
+	gosl.Test(t, limiter.ErrStopped, <-rejects)
+	gosl.Test(t, true, l.Close())
+}